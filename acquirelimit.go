@@ -0,0 +1,50 @@
+package fcntllock
+
+import (
+	"context"
+	"sync"
+)
+
+var acquireSem struct {
+	sync.Mutex
+	c chan struct{}
+}
+
+// SetMaxConcurrentAcquire bounds to n the number of in-flight lock
+// acquisitions of the process, holding open lock files during their retry
+// loop or blocking wait. The acquisitions above the limit wait for a free
+// slot before opening their lock file, respecting their context.
+//
+// n <= 0 removes the limit, which is the default.
+func SetMaxConcurrentAcquire(n int) {
+	acquireSem.Lock()
+	defer acquireSem.Unlock()
+	if n <= 0 {
+		acquireSem.c = nil
+	} else {
+		acquireSem.c = make(chan struct{}, n)
+	}
+}
+
+// acquireSlot waits for a free acquisition slot. A free slot is taken even if
+// ctx is done, so a LockContext always makes its first attempt. The returned
+// release func must be called when the acquisition is done.
+func acquireSlot(ctx context.Context) (release func(), err error) {
+	acquireSem.Lock()
+	c := acquireSem.c
+	acquireSem.Unlock()
+	if c == nil {
+		return func() {}, nil
+	}
+	select {
+	case c <- struct{}{}:
+		return func() { <-c }, nil
+	default:
+	}
+	select {
+	case c <- struct{}{}:
+		return func() { <-c }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,118 @@
+package fcntllock
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultRetryDelay is the LockContextDefault retry delay of the locks
+// without SetRetryDelay
+const defaultRetryDelay = 100 * time.Millisecond
+
+// jitterRand is the jitter random source, seeded per process so concurrent
+// processes retrying the same lock don't draw the same delays
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid())))}
+
+// jitterInt63n returns a random number in [0,n) from jitterRand
+func jitterInt63n(n int64) int64 {
+	jitterRand.Lock()
+	defer jitterRand.Unlock()
+	return jitterRand.Int63n(n)
+}
+
+type adaptiveDelay struct {
+	min, max, current time.Duration
+
+	// jitter randomizes each delay between its half and its full value
+	jitter bool
+}
+
+func newAdaptiveDelay(min, max time.Duration) *adaptiveDelay {
+	if max < min {
+		max = min
+	}
+	return &adaptiveDelay{min: min, max: max, current: min}
+}
+
+// next returns the current delay, and makes the delay grow
+func (a *adaptiveDelay) next() time.Duration {
+	d := a.current
+	if a.current *= 2; a.current > a.max || a.current <= 0 {
+		a.current = a.max
+	}
+	if a.jitter && d > 1 {
+		d = d/2 + time.Duration(jitterInt63n(int64(d/2)+1))
+	}
+	return d
+}
+
+// WithAdaptiveDelay makes the LockContext retry delay grow from min toward
+// max while the contention persists, ignoring the retryDelay argument.
+//
+// The delay is kept across acquisition attempts on the same Lock, and is
+// reset to min after a successful acquisition.
+func WithAdaptiveDelay(min, max time.Duration) Option {
+	return func(lck *Lock) {
+		lck.adaptive = newAdaptiveDelay(min, max)
+	}
+}
+
+// WithInitialSpin makes LockContext retry a contended lock n times yielding
+// the processor with runtime.Gosched, before the retries with delay, to lower
+// the acquisition latency under light contention. The default 0 disables the
+// spin.
+func WithInitialSpin(n int) Option {
+	return func(lck *Lock) {
+		lck.initialSpin = n
+	}
+}
+
+// SetRetryDelay sets the retry delay of LockContextDefault. A zero or
+// negative d restores the default 100 milliseconds.
+func (lck *Lock) SetRetryDelay(d time.Duration) {
+	lck.storedRetryDelay = d
+}
+
+// LockContextDefault is LockContext with the retry delay set by
+// SetRetryDelay.
+func (lck *Lock) LockContextDefault(ctx context.Context) error {
+	return lck.LockContext(ctx, lck.defaultRetryDelay())
+}
+
+// defaultRetryDelay returns the SetRetryDelay retry delay, or the default
+func (lck *Lock) defaultRetryDelay() time.Duration {
+	if lck.storedRetryDelay <= 0 {
+		return defaultRetryDelay
+	}
+	return lck.storedRetryDelay
+}
+
+// NextRetryDelay returns the delay the next LockContext retry will wait for.
+// It is the adaptive delay when WithAdaptiveDelay is used, else retryDelay.
+func (lck *Lock) NextRetryDelay(retryDelay time.Duration) time.Duration {
+	if lck.adaptive == nil {
+		return retryDelay
+	}
+	return lck.adaptive.current
+}
+
+// retryDelay returns the delay to wait before the next retry, and makes the
+// adaptive delay grow.
+func (lck *Lock) retryDelay(retryDelay time.Duration) time.Duration {
+	if lck.adaptive == nil {
+		return retryDelay
+	}
+	return lck.adaptive.next()
+}
+
+func (lck *Lock) resetRetryDelay() {
+	if lck.adaptive != nil {
+		lck.adaptive.current = lck.adaptive.min
+	}
+}
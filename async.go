@@ -0,0 +1,20 @@
+package fcntllock
+
+import (
+	"context"
+	"time"
+)
+
+// AcquireAsync runs LockContext in the background. The returned channel
+// delivers the LockContext error, nil on success, then is closed.
+//
+// Cancel ctx to abort the acquisition. lck must not be used until the
+// error is received.
+func (lck *Lock) AcquireAsync(ctx context.Context, retryDelay time.Duration) (done <-chan error) {
+	c := make(chan error, 1)
+	go func() {
+		defer close(c)
+		c <- lck.LockContext(ctx, retryDelay)
+	}()
+	return c
+}
@@ -0,0 +1,65 @@
+package fcntllock
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// linkFile is os.Link, replaced by tests to simulate a crash before the lock
+// file is linked into place
+var linkFile = os.Link
+
+// WithAtomicCreate makes lck create a missing lock file atomically, already
+// initialized: the WithCreateTemplate template and the WithMetadata metadata
+// are written in a temporary file of the lock directory, which is then
+// linked to the lock path, before the fcntl lock is set on the lock path.
+// So the lock file never appears half initialized, even if the creating
+// process crashes, and no O_EXCL create is needed, which is racy on some
+// network filesystems.
+//
+// A lock file already existing, or created meanwhile by another process, is
+// just opened and locked. The temporary file is linked, not renamed, so
+// a lock file created and locked meanwhile is never replaced.
+func WithAtomicCreate(v bool) Option {
+	return func(lck *Lock) {
+		lck.atomicCreate = v
+	}
+}
+
+// createAtomic creates the missing lock file from an initialized temporary
+// file
+func (lck *Lock) createAtomic() error {
+	if _, err := os.Lstat(lck.path); !os.IsNotExist(err) {
+		return nil
+	}
+	// the temporary file is created like the lock file, with the umask
+	// applied to lockFilePerm
+	dir, base := filepath.Split(lck.path)
+	name := filepath.Join(dir, fmt.Sprintf(".%s.tmp%d.%d", base, os.Getpid(), rand.Int63()))
+	tmp, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, lockFilePerm)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	err = func() error {
+		defer func() { _ = tmp.Close() }()
+		if _, err := tmp.Write(lck.createTemplate); err != nil {
+			return err
+		}
+		if lck.writesMetadata() {
+			if _, err := tmp.Write(lck.newMetadata().Bytes()); err != nil {
+				return err
+			}
+		}
+		return tmp.Sync()
+	}()
+	if err != nil {
+		return err
+	}
+	if err := linkFile(tmp.Name(), lck.path); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
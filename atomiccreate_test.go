@@ -0,0 +1,116 @@
+package fcntllock
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAtomicCreate(t *testing.T) {
+	template := []byte("# managed by myapp, do not edit\n")
+
+	t.Run("lock file is created initialized", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "lck")
+		lck := New(lockfile, WithAtomicCreate(true), WithCreateTemplate(template), WithMetadata()).(*Lock)
+		require.NoError(t, lck.TryLock())
+		defer func() { _ = lck.UnLock() }()
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, template, b[:len(template)])
+		m, err := ParseMetadata(b[len(template):])
+		require.NoError(t, err)
+		require.True(t, m.valid())
+		entries, err := ioutil.ReadDir(lockDir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "the temporary file is removed")
+	})
+
+	t.Run("existing lock file is only opened", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		content := []byte("existing\n")
+		require.NoError(t, ioutil.WriteFile(lockfile, content, 0600))
+		lck := New(lockfile, WithAtomicCreate(true), WithCreateTemplate(template)).(*Lock)
+		require.NoError(t, lck.TryLock())
+		defer func() { _ = lck.UnLock() }()
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, content, b)
+	})
+
+	t.Run("crash before the link leaves no lock file", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "lck")
+		crash := errors.New("crash")
+		linkFile = func(string, string) error { return crash }
+		defer func() { linkFile = os.Link }()
+		lck := New(lockfile, WithAtomicCreate(true), WithMetadata()).(*Lock)
+		require.ErrorIs(t, lck.TryLock(), crash)
+		entries, err := ioutil.ReadDir(lockDir)
+		require.NoError(t, err)
+		require.Empty(t, entries, "no half initialized lock file, nor temporary file")
+	})
+
+	t.Run("linked lock file has the identity metadata", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "lck")
+		var linked []byte
+		linkFile = func(oldname, newname string) error {
+			err := os.Link(oldname, newname)
+			linked, _ = ioutil.ReadFile(newname)
+			return err
+		}
+		defer func() { linkFile = os.Link }()
+		lck := New(lockfile, WithAtomicCreate(true), WithIdentity("tenant-a")).(*Lock)
+		require.NoError(t, lck.TryLock())
+		defer func() { _ = lck.UnLock() }()
+		m, err := ParseMetadata(linked)
+		require.NoError(t, err)
+		require.True(t, m.valid(), "the linked lock file must be initialized")
+		require.Equal(t, "tenant-a", m.Identity)
+	})
+
+	t.Run("observers never see a half initialized lock file", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			lockDir, cleanup := testhelper.Tempdir(t)
+			lockfile := filepath.Join(lockDir, "lck")
+			var wg sync.WaitGroup
+			stop := make(chan struct{})
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					b, err := ioutil.ReadFile(lockfile)
+					if os.IsNotExist(err) {
+						continue
+					}
+					if m, err := ParseMetadata(b); err != nil || !m.valid() {
+						t.Errorf("half initialized lock file: %q", b)
+						return
+					}
+				}
+			}()
+			lck := New(lockfile, WithAtomicCreate(true), WithMetadata()).(*Lock)
+			require.NoError(t, lck.TryLock())
+			require.NoError(t, lck.UnLock())
+			close(stop)
+			wg.Wait()
+			cleanup()
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package fcntllock
+
+import (
+	"io"
+
+	"golang.org/x/sys/unix"
+)
+
+// Backend is the store of the locks, used by the Locker methods. The default
+// backend is the fcntl lock of the lock file.
+//
+// A custom backend, like an in-memory store for tests or a distributed lock
+// service adapter, only supports the non blocking acquisitions: TryLock,
+// TryRLock and LockContext. Lock returns ErrNotSupported, and the lock file
+// features, like WithMetadata, are ignored.
+type Backend interface {
+	// TryAcquire sets the lock of type typ for l, without waiting. It
+	// returns an error matching ErrBusy if the lock is held by someone else.
+	TryAcquire(l *Lock, typ LockType) error
+
+	// Release releases the lock of l. Releasing a lock not held is not an
+	// error.
+	Release(l *Lock) error
+
+	// Probe returns the status of the lock of path, as seen by another
+	// lock holder.
+	Probe(path string) (LockStatus, error)
+}
+
+type fcntlBackend struct{}
+
+// FcntlBackend returns the fcntl lock backend, the default backend
+func FcntlBackend() Backend {
+	return fcntlBackend{}
+}
+
+// WithBackend sets the backend of the lock. A nil backend is the fcntl
+// backend.
+func WithBackend(b Backend) Option {
+	return func(lck *Lock) {
+		if _, ok := b.(fcntlBackend); ok {
+			b = nil
+		}
+		lck.backend = b
+	}
+}
+
+// Status returns the lock status of the lock path, as seen by the lock
+// backend Probe.
+func (lck *Lock) Status() (LockStatus, error) {
+	return lck.getBackend().Probe(lck.path)
+}
+
+func (lck *Lock) getBackend() Backend {
+	if lck.backend == nil {
+		return fcntlBackend{}
+	}
+	return lck.backend
+}
+
+func (fcntlBackend) TryAcquire(l *Lock, typ LockType) error {
+	return l.setFcntlLock(typ, false)
+}
+
+func (fcntlBackend) Release(l *Lock) error {
+	ft := &unix.Flock_t{
+		Start:  0,
+		Len:    0,
+		Pid:    0,
+		Type:   unix.F_UNLCK,
+		Whence: io.SeekStart,
+	}
+	return l.fcntl(unix.F_SETLK, ft)
+}
+
+func (fcntlBackend) Probe(path string) (LockStatus, error) {
+	return Probe(path)
+}
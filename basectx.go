@@ -0,0 +1,49 @@
+package fcntllock
+
+import (
+	"context"
+)
+
+// WithContext sets the base context of lck, inherited by all its
+// operations: once ctx is done, the lock attempts fail with the ctx error,
+// the LockContext waits end, and the background routines of the held lock,
+// like the WithLease renewer and the WithReassert verifier, stop.
+//
+// The held lock is not released when ctx is done, UnLock still releases it.
+func WithContext(ctx context.Context) Option {
+	return func(lck *Lock) {
+		lck.baseCtx = ctx
+	}
+}
+
+// baseDone returns the base context Done channel, or nil without base
+// context
+func (lck *Lock) baseDone() <-chan struct{} {
+	if lck.baseCtx == nil {
+		return nil
+	}
+	return lck.baseCtx.Done()
+}
+
+// baseErr returns the base context error, or nil without base context
+func (lck *Lock) baseErr() error {
+	if lck.baseCtx == nil {
+		return nil
+	}
+	return lck.baseCtx.Err()
+}
+
+// withBase returns ctx also cancelled when the base context is done
+func (lck *Lock) withBase(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if done := lck.baseDone(); done != nil {
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cancel
+}
@@ -0,0 +1,69 @@
+package fcntllock
+
+import (
+	"context"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithContext(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+
+	t.Run("lock attempts fail once the base context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		lck := New(lockfile, WithContext(ctx)).(*Lock)
+		require.NoError(t, lck.TryLock())
+		require.NoError(t, lck.UnLock())
+		cancel()
+		require.ErrorIs(t, lck.TryLock(), context.Canceled)
+		require.ErrorIs(t, lck.LockContext(context.Background(), time.Millisecond), context.Canceled)
+		require.False(t, lck.HeldByMe())
+	})
+
+	t.Run("LockContext wait ends with the base context", func(t *testing.T) {
+		forkCmd := lockInFork(t, lockfile)
+		defer func() { _ = forkCmd.Wait() }()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		lck := New(lockfile, WithContext(ctx)).(*Lock)
+		begin := time.Now()
+		err := lck.LockContext(context.Background(), 5*time.Millisecond)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, int64(time.Since(begin)), int64(60*time.Millisecond))
+	})
+
+	t.Run("background routines stop with the base context", func(t *testing.T) {
+		var calls int32
+		defer droppingFcntl(syscall.EAGAIN, 1000, &calls)()
+		ctx, cancel := context.WithCancel(context.Background())
+		lck := New(lockfile,
+			WithContext(ctx),
+			WithLease(15*time.Millisecond),
+			WithReassert(5*time.Millisecond, func(err error) {
+				t.Errorf("unexpected lock lost: %s", err)
+			})).(*Lock)
+		require.NoError(t, lck.TryLock())
+		time.Sleep(30 * time.Millisecond)
+		cancel()
+		// let the routines see the cancellation
+		time.Sleep(10 * time.Millisecond)
+		reasserted := atomic.LoadInt32(&calls)
+		require.Greater(t, reasserted, int32(1))
+		m, err := ReadMetadata(lockfile)
+		require.NoError(t, err)
+		time.Sleep(30 * time.Millisecond)
+		require.Equal(t, reasserted, atomic.LoadInt32(&calls), "no re-assertion after the base context end")
+		m2, err := ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, m.Renewed, m2.Renewed, "no lease renewal after the base context end")
+
+		require.True(t, lck.HeldByMe(), "the lock is not released")
+		require.NoError(t, lck.UnLock())
+	})
+}
@@ -0,0 +1,37 @@
+package fcntllock
+
+import (
+	"errors"
+	"syscall"
+)
+
+// WithBestEffort makes the lock attempts succeed without a lock when the
+// filesystem doesn't support the fcntl locks (ENOLCK or EOPNOTSUPP), with a
+// warning logged once. The locks work normally where supported.
+//
+// Data safety tradeoff: in degraded mode nothing is locked, so concurrent
+// holders are not excluded. Use it only when running unprotected is better
+// than not running. The kernel also returns ENOLCK when its lock table is
+// full, which is degraded too.
+func WithBestEffort(v bool) Option {
+	return func(lck *Lock) {
+		lck.bestEffort = v
+	}
+}
+
+// degraded returns true if err is a lock not supported error to ignore in
+// best effort mode, and logs the warning once
+func (lck *Lock) degraded(err error) bool {
+	if !lck.bestEffort || !isUnsupported(err) {
+		return false
+	}
+	if !lck.bestEffortWarned {
+		lck.bestEffortWarned = true
+		lck.logf("warning: locking not supported (%s), continue without lock", err)
+	}
+	return true
+}
+
+func isUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOLCK) || errors.Is(err, syscall.EOPNOTSUPP)
+}
@@ -0,0 +1,53 @@
+package fcntllock
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithBestEffort(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+
+	for _, errno := range []syscall.Errno{syscall.EOPNOTSUPP, syscall.ENOLCK} {
+		errno := errno
+		t.Run("unsupported locking "+errno.Error(), func(t *testing.T) {
+			var calls int
+			defer failingFcntl(errno, 100, &calls)()
+			var buf bytes.Buffer
+			lck := New(lockfile, WithBestEffort(true), WithLogger(log.New(&buf, "", 0))).(*Lock)
+
+			require.NoError(t, lck.TryLock())
+			require.True(t, lck.HeldByMe())
+			require.NoError(t, lck.UnLock())
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			require.NoError(t, lck.LockContext(ctx, time.Millisecond))
+			require.NoError(t, lck.UnLock())
+			require.Equal(t, 1, strings.Count(buf.String(), "warning: locking not supported"),
+				"the warning must be logged once")
+		})
+	}
+
+	t.Run("without option", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.EOPNOTSUPP, 100, &calls)()
+		require.ErrorIs(t, New(lockfile).TryLock(), syscall.EOPNOTSUPP)
+	})
+
+	t.Run("contention is not degraded", func(t *testing.T) {
+		forkCmd := lockInFork(t, lockfile)
+		lck := New(lockfile, WithBestEffort(true)).(*Lock)
+		require.Error(t, lck.TryLock())
+		require.False(t, lck.HeldByMe())
+		require.NoError(t, forkCmd.Wait())
+	})
+}
@@ -0,0 +1,127 @@
+package fcntllock
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"syscall"
+	"time"
+)
+
+// CompareAndSwap replaces the lock file content by replacement if it is
+// equal to expected, and reports if the swap happened. Used by cooperating
+// processes, it is a compare-and-swap on the lock file payload.
+//
+// The lock must be held by lck, or ErrNotLocked is returned. ErrNoFd is
+// returned when the lock is held without lock file, like with a lock
+// backend. The file offset is not moved.
+func (lck *Lock) CompareAndSwap(expected, replacement []byte) (bool, error) {
+	if !lck.HeldByMe() {
+		return false, ErrNotLocked
+	}
+	if err := lck.requireFile(); err != nil {
+		return false, err
+	}
+	current, err := lck.readContent()
+	if err != nil {
+		return false, wrapPathErr(lck.path, "read", err)
+	}
+	if !bytes.Equal(current, expected) {
+		return false, nil
+	}
+	if err := lck.writeContent(replacement); err != nil {
+		return false, wrapPathErr(lck.path, "write", err)
+	}
+	return true, nil
+}
+
+// LockIf acquires the lock like LockContext, then keeps it only if pred
+// returns true for the lock file content. Otherwise the lock is released and
+// ErrPredicateFailed is returned.
+//
+// The content is read once the lock is acquired, so it includes the
+// template and metadata written on acquisition, see WithCreateTemplate and
+// WithMetadata. ErrNoFd is returned, and the lock released, when the lock is
+// held without lock file.
+func (lck *Lock) LockIf(ctx context.Context, retryDelay time.Duration, pred func(content []byte) bool) error {
+	if err := lck.LockContext(ctx, retryDelay); err != nil {
+		return err
+	}
+	if err := lck.requireFile(); err != nil {
+		_ = lck.UnLock()
+		return err
+	}
+	b, err := lck.readContent()
+	if err != nil {
+		_ = lck.UnLock()
+		return wrapPathErr(lck.path, "read", err)
+	}
+	if !pred(b) {
+		if err := lck.UnLock(); err != nil {
+			return err
+		}
+		return ErrPredicateFailed
+	}
+	return nil
+}
+
+// readContent returns the whole lock file content, read with pread
+func (lck *Lock) readContent() ([]byte, error) {
+	return readFdContent(lck.fd)
+}
+
+// readFdContent returns the whole content of the file open as fd, read with
+// pread
+func readFdContent(fd uintptr) ([]byte, error) {
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(fd), &st); err != nil {
+		return nil, err
+	}
+	// one more byte to detect a concurrent growth
+	b := make([]byte, st.Size+1)
+	var n int
+	for {
+		count, err := pread(int(fd), b[n:], int64(n))
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return b[:n], nil
+		}
+		if n += count; n == len(b) {
+			b = append(b, make([]byte, len(b))...)
+		}
+	}
+}
+
+// preadFull reads len(b) bytes of fd at offset off, looping on the short
+// reads. It returns io.ErrUnexpectedEOF if the file is shorter.
+func preadFull(fd uintptr, b []byte, off int64) error {
+	for n := 0; n < len(b); {
+		count, err := pread(int(fd), b[n:], off+int64(n))
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		n += count
+	}
+	return nil
+}
+
+// writeContent replaces the lock file content by b, written with pwrite. The
+// file is grown back to the WithMinSize size.
+func (lck *Lock) writeContent(b []byte) error {
+	for n := 0; n < len(b); {
+		count, err := syscall.Pwrite(int(lck.fd), b[n:], int64(n))
+		if err != nil {
+			return err
+		}
+		n += count
+	}
+	if err := syscall.Ftruncate(int(lck.fd), int64(len(b))); err != nil {
+		return err
+	}
+	return lck.grow()
+}
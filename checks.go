@@ -0,0 +1,49 @@
+package fcntllock
+
+import (
+	"syscall"
+)
+
+// WithRegularFileOnly makes the lock fail with ErrNotRegularFile when the
+// lock path is not a regular file (device, socket, fifo, ...).
+func WithRegularFileOnly(v bool) Option {
+	return func(lck *Lock) {
+		lck.regularFileOnly = v
+	}
+}
+
+// WithRefuseHardlinks makes the lock fail with ErrHardlinkedLockFile when the
+// lock file has several hard links, as locking it would also lock the other
+// linked files.
+func WithRefuseHardlinks(v bool) Option {
+	return func(lck *Lock) {
+		lck.refuseHardlinks = v
+	}
+}
+
+// WithNoFollow makes the lock fail with an error matching syscall.ELOOP when
+// the lock path is a symlink, to detect a symlink planted by another user in
+// a shared directory.
+func WithNoFollow(v bool) Option {
+	return func(lck *Lock) {
+		lck.noFollow = v
+	}
+}
+
+// checkFile verifies the opened lock file fd against the lck settings
+func (lck *Lock) checkFile(fd uintptr) error {
+	if !lck.regularFileOnly && !lck.refuseHardlinks && !lck.requireOwner {
+		return nil
+	}
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(fd), &st); err != nil {
+		return err
+	}
+	if lck.regularFileOnly && uint32(st.Mode)&syscall.S_IFMT != syscall.S_IFREG {
+		return ErrNotRegularFile
+	}
+	if lck.refuseHardlinks && st.Nlink > 1 {
+		return ErrHardlinkedLockFile
+	}
+	return lck.checkOwner(&st)
+}
@@ -0,0 +1,26 @@
+package fcntllock
+
+import (
+	"syscall"
+)
+
+// WithCloseOnExec sets the lock file descriptor close-on-exec flag. The flag
+// is set by default, so the lock file descriptor is not inherited by the
+// executed child processes.
+func WithCloseOnExec(v bool) Option {
+	return func(lck *Lock) {
+		lck.noCloseOnExec = !v
+	}
+}
+
+// setCloseOnExec sets or clears the FD_CLOEXEC flag of fd
+func setCloseOnExec(fd uintptr, v bool) error {
+	var flag uintptr
+	if v {
+		flag = syscall.FD_CLOEXEC
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_SETFD, flag); errno != 0 {
+		return errno
+	}
+	return nil
+}
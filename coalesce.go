@@ -0,0 +1,152 @@
+package fcntllock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// Coalesced is a lock of a path shared by the goroutines of the process.
+	// The concurrent acquisitions of the path are coalesced into a single
+	// fcntl acquisition, held as long as a goroutine holds or waits for the
+	// lock, and the goroutines are serialized by the in-process lock of the
+	// path.
+	Coalesced struct {
+		path string
+		opts []Option
+		key  string
+	}
+
+	// coalescedEntry is the process lock of a Coalesced path
+	coalescedEntry struct {
+		lck *Lock
+
+		// refs is the number of goroutines holding or waiting for the
+		// lock
+		refs int
+
+		// held is true when lck holds the fcntl lock
+		held bool
+
+		// flight is closed when the pending fcntl acquisition completes,
+		// nil when no acquisition is pending
+		flight chan struct{}
+		err    error
+	}
+)
+
+// coalesced is the in-process registry of the Coalesced locks, by lock path
+var coalesced struct {
+	sync.Mutex
+	entries map[string]*coalescedEntry
+}
+
+// NewCoalesced returns a Coalesced lock of path. opts configure the process
+// lock, created by the first goroutine acquiring the path.
+func NewCoalesced(path string, opts ...Option) *Coalesced {
+	return &Coalesced{path: path, opts: opts, key: registryKey(path)}
+}
+
+// LockContext acquires the lock, repeating with retry delay until succeed or
+// context Done.
+//
+// If the process doesn't hold the fcntl lock yet, the first goroutine runs
+// the acquisition and the others wait for its result: a failure of the
+// acquisition is returned to all of them. Once the fcntl lock is held, the
+// goroutines wait for the in-process lock of the path, like
+// AcquireWithPriority with priority 0.
+func (c *Coalesced) LockContext(ctx context.Context, retryDelay time.Duration) error {
+	e, err := c.acquireProcessLock(ctx, retryDelay)
+	if err != nil {
+		return err
+	}
+	if err := acquireLocal(ctx, c.key, 0); err != nil {
+		_ = c.release(e)
+		return err
+	}
+	return nil
+}
+
+// UnLock releases the in-process lock, and the fcntl lock if no other
+// goroutine holds or waits for it. It must be called once per successful
+// LockContext.
+func (c *Coalesced) UnLock() error {
+	coalesced.Lock()
+	e, ok := coalesced.entries[c.key]
+	coalesced.Unlock()
+	if !ok {
+		return ErrNotLocked
+	}
+	releaseLocal(c.key)
+	return c.release(e)
+}
+
+// acquireProcessLock registers the caller on the process lock entry of the
+// path, and returns when the entry fcntl lock is held.
+func (c *Coalesced) acquireProcessLock(ctx context.Context, retryDelay time.Duration) (*coalescedEntry, error) {
+	coalesced.Lock()
+	if coalesced.entries == nil {
+		coalesced.entries = make(map[string]*coalescedEntry)
+	}
+	e, ok := coalesced.entries[c.key]
+	if !ok {
+		e = &coalescedEntry{lck: New(c.path, c.opts...).(*Lock)}
+		coalesced.entries[c.key] = e
+	}
+	e.refs++
+	if e.held {
+		coalesced.Unlock()
+		return e, nil
+	}
+	flight := e.flight
+	if flight == nil {
+		// this goroutine runs the acquisition
+		flight = make(chan struct{})
+		e.flight = flight
+		coalesced.Unlock()
+		err := e.lck.LockContext(ctx, retryDelay)
+		coalesced.Lock()
+		e.err = err
+		e.held = err == nil
+		e.flight = nil
+		close(flight)
+		coalesced.Unlock()
+		if err != nil {
+			_ = c.release(e)
+			return nil, err
+		}
+		return e, nil
+	}
+	coalesced.Unlock()
+
+	select {
+	case <-flight:
+	case <-ctx.Done():
+		_ = c.release(e)
+		return nil, ctx.Err()
+	}
+	coalesced.Lock()
+	err := e.err
+	coalesced.Unlock()
+	if err != nil {
+		_ = c.release(e)
+		return nil, err
+	}
+	return e, nil
+}
+
+// release unregisters the caller from e, and releases the fcntl lock when
+// the last caller is gone.
+func (c *Coalesced) release(e *coalescedEntry) (err error) {
+	coalesced.Lock()
+	defer coalesced.Unlock()
+	if e.refs--; e.refs > 0 {
+		return nil
+	}
+	if e.held {
+		err = e.lck.UnLock()
+	}
+	delete(coalesced.entries, c.key)
+	return err
+}
@@ -0,0 +1,99 @@
+package fcntllock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// coalescedRefs returns the number of goroutines registered on the
+// Coalesced lock entry of path
+func coalescedRefs(path string) int {
+	coalesced.Lock()
+	defer coalesced.Unlock()
+	if e, ok := coalesced.entries[registryKey(path)]; ok {
+		return e.refs
+	}
+	return 0
+}
+
+func TestCoalesced(t *testing.T) {
+	t.Run("concurrent acquisitions share one fcntl acquisition", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		const n = 20
+		var calls int32
+		gate := make(chan struct{})
+		defer injectFcntl(func(fd uintptr, cmd int, lk *unix.Flock_t) error {
+			if cmd == unix.F_SETLK && lk.Type != unix.F_UNLCK {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					// hold the first acquisition until all goroutines wait
+					<-gate
+				}
+			}
+			return unix.FcntlFlock(fd, cmd, lk)
+		})()
+
+		var (
+			wg      sync.WaitGroup
+			inside  int32
+			overlap int32
+			done    int32
+		)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c := NewCoalesced(lockfile)
+				if err := c.LockContext(context.Background(), 10*time.Millisecond); err != nil {
+					t.Errorf("LockContext: %s", err)
+					return
+				}
+				if atomic.AddInt32(&inside, 1) > 1 {
+					atomic.StoreInt32(&overlap, 1)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&inside, -1)
+				atomic.AddInt32(&done, 1)
+				if err := c.UnLock(); err != nil {
+					t.Errorf("UnLock: %s", err)
+				}
+			}()
+		}
+		require.Eventually(t, func() bool { return coalescedRefs(lockfile) == n }, time.Second, time.Millisecond)
+		close(gate)
+		wg.Wait()
+
+		require.Equal(t, int32(n), done)
+		require.Equal(t, int32(0), overlap, "holders are serialized")
+		require.Equal(t, int32(1), atomic.LoadInt32(&calls), "one fcntl acquisition")
+		require.Equal(t, 0, coalescedRefs(lockfile))
+
+		// the fcntl lock is released with the last holder
+		l := New(lockfile).(*Lock)
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("acquisition failure unregisters the caller", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		var calls int
+		defer failingFcntl(unix.EPERM, 1, &calls)()
+		err := NewCoalesced(lockfile).LockContext(context.Background(), time.Millisecond)
+		require.ErrorIs(t, err, unix.EPERM)
+		require.Equal(t, 0, coalescedRefs(lockfile))
+	})
+
+	t.Run("unlock without lock", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		require.ErrorIs(t, NewCoalesced(lockfile).UnLock(), ErrNotLocked)
+	})
+}
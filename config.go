@@ -0,0 +1,216 @@
+package fcntllock
+
+import (
+	"time"
+)
+
+// LockConfig is a JSON marshalable snapshot of the settings of a Lock, see
+// Config and NewFromConfig.
+//
+// The callbacks and interfaces settings, like the logger, the metrics sink,
+// the post acquire hook, the lock backend, the base context and the
+// WithReassert callback, are not part of the snapshot.
+type LockConfig struct {
+	// Path is the effective lock file path, suffix included
+	Path string `json:"path"`
+
+	// OFD is the WithOFD setting
+	OFD bool `json:"ofd,omitempty"`
+
+	// BestEffort is the WithBestEffort setting
+	BestEffort bool `json:"best_effort,omitempty"`
+
+	// Reentrant is the WithReentrant setting
+	Reentrant bool `json:"reentrant,omitempty"`
+
+	// RegularFileOnly and RefuseHardlinks are the lock file checks
+	RegularFileOnly bool `json:"regular_file_only,omitempty"`
+	RefuseHardlinks bool `json:"refuse_hardlinks,omitempty"`
+
+	// NoFollow is the WithNoFollow setting
+	NoFollow bool `json:"no_follow,omitempty"`
+
+	// InodeCheck is the WithInodeCheck setting
+	InodeCheck bool `json:"inode_check,omitempty"`
+
+	// RequireOwner is the WithRequireOwner uid, nil without owner check
+	RequireOwner *int `json:"require_owner,omitempty"`
+
+	// CloseOnExec is the WithCloseOnExec setting
+	CloseOnExec bool `json:"close_on_exec"`
+
+	// CreateDir is the WithCreateDir setting
+	CreateDir bool `json:"create_dir"`
+
+	// EnsureDirOnPermError is the WithEnsureDirOnPermError setting
+	EnsureDirOnPermError bool `json:"ensure_dir_on_perm_error,omitempty"`
+
+	// EACCESAsContention is the WithEACCESAsContention setting
+	EACCESAsContention bool `json:"eacces_as_contention"`
+
+	// ENOLCKRetries is the WithENOLCKRetries setting
+	ENOLCKRetries int `json:"enolck_retries"`
+
+	// InaccessiblePolicy is the WithInaccessiblePolicy setting
+	InaccessiblePolicy InaccessiblePolicy `json:"inaccessible_policy,omitempty"`
+
+	// AdaptiveMin and AdaptiveMax are the WithAdaptiveDelay retry delay
+	// bounds, zero without adaptive delay
+	AdaptiveMin time.Duration `json:"adaptive_min,omitempty"`
+	AdaptiveMax time.Duration `json:"adaptive_max,omitempty"`
+
+	// FcntlTimeout is the WithFcntlTimeout setting
+	FcntlTimeout time.Duration `json:"fcntl_timeout,omitempty"`
+
+	// FIFOSkipAfter is the WithFIFO setting, zero without FIFO ordering
+	FIFOSkipAfter time.Duration `json:"fifo_skip_after,omitempty"`
+
+	// InitialSpin is the WithInitialSpin setting
+	InitialSpin int `json:"initial_spin,omitempty"`
+
+	// ContentionCounter is the WithContentionCounter setting
+	ContentionCounter bool `json:"contention_counter,omitempty"`
+
+	// WaitBuckets are the WithWaitBuckets histogram buckets
+	WaitBuckets []time.Duration `json:"wait_buckets,omitempty"`
+
+	// CreateTemplate is the WithCreateTemplate template
+	CreateTemplate []byte `json:"create_template,omitempty"`
+
+	// TruncateOnLock, MinSize, ForceCopyUp and AtomicCreate are the lock
+	// file content and creation settings
+	TruncateOnLock bool  `json:"truncate_on_lock,omitempty"`
+	MinSize        int64 `json:"min_size,omitempty"`
+	ForceCopyUp    bool  `json:"force_copy_up,omitempty"`
+	AtomicCreate   bool  `json:"atomic_create,omitempty"`
+
+	// ReleaseOnOrphan is the WithReleaseOnOrphan setting, and
+	// ExitOnOrphan the WithExitOnOrphan exit code, nil without exit
+	ReleaseOnOrphan bool `json:"release_on_orphan,omitempty"`
+	ExitOnOrphan    *int `json:"exit_on_orphan,omitempty"`
+
+	// Identity is the WithIdentity identity
+	Identity string `json:"identity,omitempty"`
+
+	// Metadata is the WithMetadata setting, and RecordWait the
+	// WithRecordWait setting
+	Metadata   bool `json:"metadata,omitempty"`
+	RecordWait bool `json:"record_wait,omitempty"`
+
+	// Lease is the WithLease ttl, zero without lease
+	Lease time.Duration `json:"lease,omitempty"`
+}
+
+// Config returns the snapshot of the lck settings
+func (lck *Lock) Config() LockConfig {
+	c := LockConfig{
+		Path:                 lck.path,
+		OFD:                  lck.ofd,
+		BestEffort:           lck.bestEffort,
+		Reentrant:            lck.reentrant,
+		RegularFileOnly:      lck.regularFileOnly,
+		RefuseHardlinks:      lck.refuseHardlinks,
+		NoFollow:             lck.noFollow,
+		InodeCheck:           lck.inodeCheck,
+		CloseOnExec:          !lck.noCloseOnExec,
+		CreateDir:            !lck.noCreateDir,
+		EnsureDirOnPermError: lck.ensureDirOnPermError,
+		EACCESAsContention:   !lck.eaccesNotContention,
+		ENOLCKRetries:        lck.enolckRetries,
+		InaccessiblePolicy:   lck.inaccessiblePolicy,
+		FcntlTimeout:         lck.fcntlTimeout,
+		FIFOSkipAfter:        lck.fifoSkipAfter,
+		InitialSpin:          lck.initialSpin,
+		ContentionCounter:    lck.contentionCounter,
+		TruncateOnLock:       lck.truncateOnLock,
+		MinSize:              lck.minSize,
+		ForceCopyUp:          lck.forceCopyUp,
+		AtomicCreate:         lck.atomicCreate,
+		Identity:             lck.identity,
+		Metadata:             lck.metadata,
+		RecordWait:           lck.recordWait,
+	}
+	if lck.requireOwner {
+		uid := lck.ownerUID
+		c.RequireOwner = &uid
+	}
+	if lck.orphan != nil {
+		c.ReleaseOnOrphan = true
+		if lck.orphan.exit {
+			code := lck.orphan.exitCode
+			c.ExitOnOrphan = &code
+		}
+	}
+	if lck.adaptive != nil {
+		c.AdaptiveMin, c.AdaptiveMax = lck.adaptive.min, lck.adaptive.max
+	}
+	if lck.waits != nil {
+		c.WaitBuckets = append([]time.Duration{}, lck.waits.buckets...)
+	}
+	if lck.createTemplate != nil {
+		c.CreateTemplate = append([]byte{}, lck.createTemplate...)
+	}
+	if lck.lease != nil {
+		c.Lease = lck.lease.ttl
+	}
+	return c
+}
+
+// NewFromConfig returns a lock with the c settings, like the lock c is the
+// Config of. opts are applied after the c settings, for example to set the
+// settings not part of a LockConfig.
+func NewFromConfig(c LockConfig, opts ...Option) Locker {
+	o := []Option{
+		WithOFD(c.OFD),
+		WithBestEffort(c.BestEffort),
+		WithReentrant(c.Reentrant),
+		WithRegularFileOnly(c.RegularFileOnly),
+		WithRefuseHardlinks(c.RefuseHardlinks),
+		WithNoFollow(c.NoFollow),
+		WithInodeCheck(c.InodeCheck),
+		WithCloseOnExec(c.CloseOnExec),
+		WithCreateDir(c.CreateDir),
+		WithEnsureDirOnPermError(c.EnsureDirOnPermError),
+		WithEACCESAsContention(c.EACCESAsContention),
+		WithENOLCKRetries(c.ENOLCKRetries),
+		WithInaccessiblePolicy(c.InaccessiblePolicy),
+		WithFcntlTimeout(c.FcntlTimeout),
+		WithFIFO(c.FIFOSkipAfter),
+		WithInitialSpin(c.InitialSpin),
+		WithTruncateOnLock(c.TruncateOnLock),
+		WithMinSize(c.MinSize),
+		WithForceCopyUp(c.ForceCopyUp),
+		WithAtomicCreate(c.AtomicCreate),
+		WithIdentity(c.Identity),
+	}
+	if c.RequireOwner != nil {
+		o = append(o, WithRequireOwner(*c.RequireOwner))
+	}
+	if c.ExitOnOrphan != nil {
+		o = append(o, WithExitOnOrphan(*c.ExitOnOrphan))
+	} else if c.ReleaseOnOrphan {
+		o = append(o, WithReleaseOnOrphan(true))
+	}
+	if c.AdaptiveMin > 0 || c.AdaptiveMax > 0 {
+		o = append(o, WithAdaptiveDelay(c.AdaptiveMin, c.AdaptiveMax))
+	}
+	if c.ContentionCounter {
+		o = append(o, WithContentionCounter())
+	}
+	if c.WaitBuckets != nil {
+		o = append(o, WithWaitBuckets(c.WaitBuckets))
+	}
+	if c.CreateTemplate != nil {
+		o = append(o, WithCreateTemplate(c.CreateTemplate))
+	}
+	if c.Metadata {
+		o = append(o, WithMetadata())
+	}
+	if c.RecordWait {
+		o = append(o, WithRecordWait())
+	}
+	if c.Lease > 0 {
+		o = append(o, WithLease(c.Lease))
+	}
+	return New(c.Path, append(o, opts...)...)
+}
@@ -0,0 +1,117 @@
+package fcntllock
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// contentionMu serializes the contention counter updates of the process, as
+// fcntl never reports a conflict between locks of the same process
+var contentionMu sync.Mutex
+
+// WithContentionCounter maintains a cooperative waiters counter in the
+// "<path>.waiters" sidecar file: incremented while a LockContext waits for
+// the lock, and decremented when it acquires or gives up. See
+// ContentionDepth.
+func WithContentionCounter() Option {
+	return func(lck *Lock) {
+		lck.contentionCounter = true
+	}
+}
+
+// ContentionDepth returns a best effort estimate of the number of waiters for
+// the lock, read from the WithContentionCounter sidecar file.
+//
+// Only the waiters using WithContentionCounter are counted, and a waiter
+// killed while waiting is never uncounted.
+func (lck *Lock) ContentionDepth() (int, error) {
+	contentionMu.Lock()
+	defer contentionMu.Unlock()
+	var n int
+	err := lck.withCounterFile(unix.F_RDLCK, func(f *os.File) (err error) {
+		n, err = readCounter(f)
+		return
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return n, wrapPathErr(lck.path, "read", err)
+}
+
+func (lck *Lock) counterPath() string {
+	return lck.path + ".waiters"
+}
+
+// addContention adds delta to the waiters counter
+func (lck *Lock) addContention(delta int) error {
+	contentionMu.Lock()
+	defer contentionMu.Unlock()
+	return lck.withCounterFile(unix.F_WRLCK, func(f *os.File) error {
+		n, err := readCounter(f)
+		if err != nil {
+			return err
+		}
+		if n += delta; n < 0 {
+			n = 0
+		}
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		_, err = f.WriteAt([]byte(strconv.Itoa(n)+"\n"), 0)
+		return err
+	})
+}
+
+// withCounterFile runs fn with the counter file opened and locked with typ
+func (lck *Lock) withCounterFile(typ int16, fn func(f *os.File) error) error {
+	flag := os.O_RDONLY
+	if typ == unix.F_WRLCK {
+		flag = os.O_RDWR | os.O_CREATE
+	}
+	f, err := os.OpenFile(lck.counterPath(), flag, 0666)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if err := fcntlFlock(f.Fd(), unix.F_SETLKW, wholeFileLock(typ)); err != nil {
+		return err
+	}
+	return fn(f)
+}
+
+func readCounter(f *os.File) (int, error) {
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// countedWait returns fn counting the caller as a waiter on its first
+// contention, and the func uncounting the caller, to call when the wait is
+// over
+func (lck *Lock) countedWait(fn func() error) (counted func() error, uncount func()) {
+	var waiting bool
+	counted = func() error {
+		err := fn()
+		if !waiting && lck.isContended(err) {
+			waiting = lck.addContention(1) == nil
+		}
+		return err
+	}
+	uncount = func() {
+		if waiting {
+			_ = lck.addContention(-1)
+		}
+	}
+	return
+}
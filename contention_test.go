@@ -0,0 +1,102 @@
+package fcntllock
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// failingFcntl makes the first n F_SETLK fcntlFlock calls return errno,
+// until the returned restore func is called. calls counts the F_SETLK calls.
+func failingFcntl(errno syscall.Errno, n int, calls *int) (restore func()) {
+	return injectFcntl(func(fd uintptr, cmd int, lk *unix.Flock_t) error {
+		if cmd == unix.F_SETLK && lk.Type != unix.F_UNLCK {
+			*calls++
+			if *calls <= n {
+				return errno
+			}
+		}
+		return unix.FcntlFlock(fd, cmd, lk)
+	})
+}
+
+func TestWithEACCESAsContention(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+
+	t.Run("fcntl EACCES is contention by default", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.EACCES, 2, &calls)()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		l := New(lockfile)
+		require.NoError(t, l.LockContext(ctx, time.Millisecond))
+		require.Equal(t, 3, calls)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("fcntl EACCES is a permission error when disabled", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.EACCES, 2, &calls)()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		l := New(lockfile, WithEACCESAsContention(false))
+		err := l.LockContext(ctx, time.Millisecond)
+		require.ErrorIs(t, err, os.ErrPermission)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("fcntl EAGAIN is always contention", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.EAGAIN, 2, &calls)()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		l := New(lockfile, WithEACCESAsContention(false))
+		require.NoError(t, l.LockContext(ctx, time.Millisecond))
+		require.Equal(t, 3, calls)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("open EACCES is a permission error", func(t *testing.T) {
+		var calls int
+		defer failingOpen(syscall.EACCES, &calls)()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := New(lockfile).LockContext(ctx, time.Millisecond)
+		require.ErrorIs(t, err, os.ErrPermission)
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestENOLCKRetries(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+
+	t.Run("transient ENOLCK is retried", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.ENOLCK, 3, &calls)()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		l := New(lockfile)
+		require.NoError(t, l.LockContext(ctx, time.Millisecond))
+		require.Equal(t, 4, calls)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("persistent ENOLCK gives up", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.ENOLCK, 100, &calls)()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := New(lockfile, WithENOLCKRetries(2)).LockContext(ctx, time.Millisecond)
+		require.ErrorIs(t, err, ErrLockTableFull)
+		require.ErrorIs(t, err, syscall.ENOLCK)
+		require.Equal(t, 3, calls)
+	})
+}
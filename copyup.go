@@ -0,0 +1,55 @@
+package fcntllock
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// WithForceCopyUp makes the lock file open trigger the overlayfs copy-up of
+// the lock file, before the fcntl lock is set.
+//
+// On overlayfs, a file of the lower layer is copied to the upper layer on
+// its first write. A file descriptor opened before the copy-up may still
+// refer to the lower layer inode, so processes may lock distinct inodes for
+// the same lock path. With WithForceCopyUp, a zero length write is done on
+// the opened lock file, and the lock file is opened again if its file
+// descriptor doesn't refer to the lock path inode, so the lock applies to the
+// upper layer inode. It is harmless on the other filesystems.
+func WithForceCopyUp(v bool) Option {
+	return func(lck *Lock) {
+		lck.forceCopyUp = v
+	}
+}
+
+// copyUp triggers the copy-up of the opened lock file, and returns the lock
+// file opened again with flags if file is not the lock path inode
+func (lck *Lock) copyUp(file *os.File, flags int) (*os.File, error) {
+	if _, err := unix.Pwrite(int(file.Fd()), []byte{}, 0); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	same, err := lck.isPathInode(file.Fd())
+	if err != nil || same {
+		if err != nil {
+			_ = file.Close()
+		}
+		return file, err
+	}
+	lck.logf("lock file copied up, open again")
+	_ = file.Close()
+	return openFile(lck.path, flags, lockFilePerm)
+}
+
+// isPathInode returns true if fd refers to the lock path inode
+func (lck *Lock) isPathInode(fd uintptr) (bool, error) {
+	var fdSt, pathSt syscall.Stat_t
+	if err := syscall.Fstat(int(fd), &fdSt); err != nil {
+		return false, err
+	}
+	if err := syscall.Stat(lck.path, &pathSt); err != nil {
+		return false, err
+	}
+	return fdSt.Dev == pathSt.Dev && fdSt.Ino == pathSt.Ino, nil
+}
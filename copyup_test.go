@@ -0,0 +1,64 @@
+package fcntllock
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+// lowerLayerOpen makes the first openFile call open lower instead of the
+// requested file, simulating a file descriptor of the overlayfs lower layer
+// inode. calls counts the openFile calls.
+func lowerLayerOpen(lower string, calls *int) (restore func()) {
+	return injectOpen(func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		if *calls++; *calls == 1 {
+			return os.OpenFile(lower, flag&^os.O_EXCL, perm)
+		}
+		return os.OpenFile(name, flag, perm)
+	})
+}
+
+func TestWithForceCopyUp(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+	lower, lowerCleanup := testhelper.TempFile(t)
+	defer lowerCleanup()
+
+	t.Run("lock targets the lock path inode", func(t *testing.T) {
+		var calls int
+		defer lowerLayerOpen(lower, &calls)()
+		lck := New(lockfile, WithForceCopyUp(true)).(*Lock)
+		require.NoError(t, lck.TryLock())
+		defer func() { _ = lck.UnLock() }()
+		require.Equal(t, 2, calls, "the lock file must be opened again")
+		same, err := lck.isPathInode(lck.fd)
+		require.NoError(t, err)
+		require.True(t, same)
+	})
+
+	t.Run("lock targets the lower inode without the option", func(t *testing.T) {
+		var calls int
+		defer lowerLayerOpen(lower, &calls)()
+		lck := New(lockfile).(*Lock)
+		require.NoError(t, lck.TryLock())
+		defer func() { _ = lck.UnLock() }()
+		require.Equal(t, 1, calls)
+		same, err := lck.isPathInode(lck.fd)
+		require.NoError(t, err)
+		require.False(t, same)
+	})
+
+	t.Run("harmless on a regular filesystem", func(t *testing.T) {
+		content := []byte("keep me\n")
+		require.NoError(t, ioutil.WriteFile(lockfile, content, 0600))
+		lck := New(lockfile, WithForceCopyUp(true)).(*Lock)
+		require.NoError(t, lck.TryLock())
+		defer func() { _ = lck.UnLock() }()
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, content, b)
+	})
+}
@@ -0,0 +1,57 @@
+package fcntllock
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSettings are the package level settings, applying to all locks
+type DefaultSettings struct {
+	// LockDirPerm is the mode of the created lock directories, see
+	// SetDefaultLockDirPerm
+	LockDirPerm os.FileMode
+
+	// LockFilePerm is the mode of the created lock files, before umask
+	LockFilePerm os.FileMode
+
+	// LockFileFlags are the lock file open flags
+	LockFileFlags int
+
+	// ENOLCKRetries is the default WithENOLCKRetries setting
+	ENOLCKRetries int
+
+	// MaxConcurrentAcquire is the SetMaxConcurrentAcquire limit, 0 for no
+	// limit
+	MaxConcurrentAcquire int
+
+	// MaxOpenLocks is the SetMaxOpenLocks limit, 0 for no limit
+	MaxOpenLocks int
+
+	// RetryDelay is the LockContextDefault retry delay of the locks without
+	// SetRetryDelay
+	RetryDelay time.Duration
+}
+
+// SetDefaultLockDirPerm sets the mode of the lock directories created by the
+// package, before umask. The default is 0700. It is meant to be called on
+// initialization, before any lock.
+func SetDefaultLockDirPerm(perm os.FileMode) {
+	lockDirPerm = perm
+}
+
+// Defaults returns the current package level settings
+func Defaults() DefaultSettings {
+	acquireSem.Lock()
+	maxConcurrentAcquire := cap(acquireSem.c)
+	acquireSem.Unlock()
+	return DefaultSettings{
+		LockDirPerm:          lockDirPerm,
+		LockFilePerm:         lockFilePerm,
+		LockFileFlags:        lockFileFlags,
+		ENOLCKRetries:        defaultENOLCKRetries,
+		MaxConcurrentAcquire: maxConcurrentAcquire,
+		MaxOpenLocks:         int(atomic.LoadInt64(&maxOpenFDs)),
+		RetryDelay:           defaultRetryDelay,
+	}
+}
@@ -0,0 +1,61 @@
+package fcntllock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureDirConcurrent(t *testing.T) {
+	t.Run("concurrent creations of the same directory", func(t *testing.T) {
+		tmpDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		dir := filepath.Join(tmpDir, "a", "b", "c")
+		var wg sync.WaitGroup
+		errs := make(chan error, 50)
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- New(filepath.Join(dir, "lck")).(*Lock).createLockDir()
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+		require.DirExists(t, dir)
+	})
+
+	t.Run("EEXIST of a directory created meanwhile is a success", func(t *testing.T) {
+		tmpDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		dir := filepath.Join(tmpDir, "lockdir")
+		defer func() { mkdirAll = os.MkdirAll }()
+		mkdirAll = func(path string, perm os.FileMode) error {
+			// another process wins the race
+			_ = os.Mkdir(path, perm)
+			return &os.PathError{Op: "mkdir", Path: path, Err: syscall.EEXIST}
+		}
+		require.NoError(t, ensureDir(dir))
+	})
+
+	t.Run("EEXIST of a file created meanwhile is an error", func(t *testing.T) {
+		tmpDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		dir := filepath.Join(tmpDir, "lockdir")
+		defer func() { mkdirAll = os.MkdirAll }()
+		mkdirAll = func(path string, perm os.FileMode) error {
+			_ = ioutil.WriteFile(path, nil, 0600)
+			return &os.PathError{Op: "mkdir", Path: path, Err: syscall.EEXIST}
+		}
+		require.ErrorIs(t, ensureDir(dir), os.ErrExist)
+	})
+}
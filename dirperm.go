@@ -0,0 +1,39 @@
+package fcntllock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// WithEnsureDirOnPermError makes LockContext restore the lock directory
+// permissions once, when a lock attempt fails with a permission error.
+//
+// The scope is narrow: the restore is only done if the lock directory is
+// owned by the process effective user, and only adds the default lock
+// directory permissions bits.
+func WithEnsureDirOnPermError(v bool) Option {
+	return func(lck *Lock) {
+		lck.ensureDirOnPermError = v
+	}
+}
+
+// ensureLockDirPerm adds the lockDirPerm bits to the lock directory of path,
+// if the directory is owned by the process effective user.
+func ensureLockDirPerm(path string) error {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || int(st.Uid) != os.Geteuid() {
+		return errors.New("lock directory is not owned by the process user: " + dir)
+	}
+	perm := info.Mode().Perm()
+	if perm&lockDirPerm == lockDirPerm {
+		return errors.New("lock directory permissions already ensured: " + dir)
+	}
+	return os.Chmod(dir, perm|lockDirPerm)
+}
@@ -0,0 +1,188 @@
+package fcntllock
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	// ErrNotLocked is returned by methods requiring the lock to be held by
+	// the caller.
+	ErrNotLocked = errors.New("lock is not held")
+
+	// ErrOpenWouldBlock is returned when the lock file is a special file
+	// that can't be opened without blocking, like a fifo without reader.
+	ErrOpenWouldBlock = errors.New("lock file open would block")
+
+	// ErrNotRegularFile is returned when WithRegularFileOnly is set and the
+	// lock file is not a regular file.
+	ErrNotRegularFile = errors.New("lock file is not a regular file")
+
+	// ErrHardlinkedLockFile is returned when WithRefuseHardlinks is set and
+	// the lock file has several hard links.
+	ErrHardlinkedLockFile = errors.New("lock file has several hard links")
+
+	// ErrWrongOwner is returned when WithRequireOwner is set and the lock
+	// file is owned by another user.
+	ErrWrongOwner = errors.New("lock file is owned by an unexpected user")
+
+	// ErrWouldSelfDeadlock is returned by Lock when the lock is already held
+	// by the same Lock.
+	ErrWouldSelfDeadlock = errors.New("lock is already held by this lock")
+
+	// ErrNoSpace is returned when the lock file can't be created because
+	// the filesystem is full. It is never retried by LockContext.
+	ErrNoSpace = errors.New("no space left to create the lock file")
+
+	// ErrTextBusy is returned when the lock path is a running executable,
+	// which can't be opened for writing. It is never retried by
+	// LockContext.
+	ErrTextBusy = errors.New("lock file is a running executable")
+
+	// ErrInvalidName is returned by SanitizeName for an unsafe lock name.
+	ErrInvalidName = errors.New("invalid lock name")
+
+	// ErrLockLost is returned by Revalidate when the lock file descriptor or
+	// the lock are no longer valid.
+	ErrLockLost = errors.New("lock lost")
+
+	// ErrLockFileReplaced is returned by UnLock and Verify when the lock
+	// path no longer points to the locked file, see WithInodeCheck.
+	ErrLockFileReplaced = errors.New("lock file replaced")
+
+	// ErrNoFd is returned by NewFromRWSC when the lock file object can't
+	// yield a usable file descriptor, and by LockAndOpen and the lock file
+	// content methods when the lock is held without lock file.
+	ErrNoFd = errors.New("lock file has no usable file descriptor")
+
+	// ErrFcntlTimeout is returned when a non blocking fcntl lock call
+	// doesn't return within the WithFcntlTimeout duration.
+	ErrFcntlTimeout = errors.New("fcntl lock call timeout")
+
+	// ErrLockTableFull is returned when a lock attempt fails with ENOLCK
+	// after the WithENOLCKRetries retries.
+	ErrLockTableFull = errors.New("lock table full")
+
+	// ErrLockFileInaccessible is returned when the lock file exists but
+	// can't be opened for permission reasons, and the
+	// InaccessibleAsError policy is set.
+	ErrLockFileInaccessible = errors.New("lock file exists but is not accessible")
+
+	// ErrBusy is returned by the lock backends when the lock is held by
+	// someone else. It is retried by LockContext.
+	ErrBusy = errors.New("lock is held by someone else")
+
+	// ErrTooManyOpenLocks is returned when opening a lock file would exceed
+	// the SetMaxOpenLocks limit.
+	ErrTooManyOpenLocks = errors.New("too many open lock files")
+
+	// ErrNotSupported is returned by the features not supported on the
+	// platform or by the lock backend.
+	ErrNotSupported = errors.New("not supported")
+
+	// ErrConditionFalse is returned by LockWhile when its condition turns
+	// false before the lock is acquired.
+	ErrConditionFalse = errors.New("lock condition is false")
+
+	// ErrPredicateFailed is returned by LockIf when the lock file content
+	// doesn't satisfy its predicate.
+	ErrPredicateFailed = errors.New("lock file content predicate failed")
+
+	// ErrPreempted is returned by LockContextPreempt when its preempt
+	// channel fires before the lock is acquired.
+	ErrPreempted = errors.New("lock wait preempted")
+
+	// ErrInvalidIdentity is returned by the lock attempts when the
+	// WithIdentity identity contains control characters.
+	ErrInvalidIdentity = errors.New("invalid lock holder identity")
+
+	// ErrNoHolder is returned by SignalHolder when the lock is free.
+	ErrNoHolder = errors.New("lock has no holder")
+
+	// ErrHolderUnknown is returned by SignalHolder when the lock holder
+	// pid can't be determined.
+	ErrHolderUnknown = errors.New("lock holder pid is unknown")
+
+	// ErrAlreadyRunning is matched by the AlreadyRunningError returned by
+	// SingleInstance when another instance is running.
+	ErrAlreadyRunning = errors.New("another instance is already running")
+)
+
+// AlreadyRunningError is the SingleInstance error when the single instance
+// lock is held by another instance. It matches ErrAlreadyRunning.
+type AlreadyRunningError struct {
+	// Path is the single instance lock path
+	Path string
+
+	// PID is the process id of the running instance, 0 if unknown
+	PID int
+}
+
+// Error returns the running instance pid, if known
+func (e *AlreadyRunningError) Error() string {
+	if e.PID <= 0 {
+		return ErrAlreadyRunning.Error()
+	}
+	return ErrAlreadyRunning.Error() + ": pid " + strconv.Itoa(e.PID)
+}
+
+// Is reports if target is ErrAlreadyRunning
+func (e *AlreadyRunningError) Is(target error) bool {
+	return target == ErrAlreadyRunning
+}
+
+// LockError records an error of a lock operation and the lock path, like
+// *os.PathError. The lock methods errors are *LockError, except the
+// package sentinel and context errors returned as is.
+type LockError struct {
+	// Path is the lock path
+	Path string
+
+	// Op is the failed operation: "mkdir", "open", "lock", "unlock",
+	// "write", ...
+	Op string
+
+	// Err is the operation error
+	Err error
+}
+
+// Error returns the lock path and the operation error message
+func (e *LockError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return "fcntllock " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the operation error
+func (e *LockError) Unwrap() error {
+	return e.Err
+}
+
+// opError tags err with the failed operation op, kept by wrapPathErr, or
+// returns nil if err is nil
+func opError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &LockError{Op: op, Err: err}
+}
+
+// sentinelError is an error matching both a package sentinel error and the
+// underlying cause with errors.Is.
+type sentinelError struct {
+	sentinel error
+	err      error
+}
+
+func (e *sentinelError) Error() string {
+	return e.sentinel.Error() + ": " + e.err.Error()
+}
+
+func (e *sentinelError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+func (e *sentinelError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,107 @@
+package fcntllock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// injectFcntl replaces the fcntlFlock syscall seam by fn, until the returned
+// restore func is called
+func injectFcntl(fn func(fd uintptr, cmd int, lk *unix.Flock_t) error) (restore func()) {
+	fcntlFlock = fn
+	return func() { fcntlFlock = unix.FcntlFlock }
+}
+
+// injectOpen replaces the openFile seam by fn, until the returned restore
+// func is called
+func injectOpen(fn func(name string, flag int, perm os.FileMode) (*os.File, error)) (restore func()) {
+	openFile = fn
+	return func() { openFile = os.OpenFile }
+}
+
+func TestInjectedFcntlErrno(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+
+	t.Run("EAGAIN is retried by LockContext", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.EAGAIN, 2, &calls)()
+		l := New(lockfile)
+		require.ErrorIs(t, l.TryLock(), syscall.EAGAIN)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, l.LockContext(ctx, time.Millisecond))
+		require.Equal(t, 3, calls)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("ENOLCK is retried by LockContext", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.ENOLCK, 2, &calls)()
+		l := New(lockfile)
+		require.ErrorIs(t, l.TryLock(), syscall.ENOLCK)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, l.LockContext(ctx, time.Millisecond))
+		require.Equal(t, 3, calls)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("EINTR of a non blocking lock fails fast", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.EINTR, 100, &calls)()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := New(lockfile).LockContext(ctx, time.Millisecond)
+		require.ErrorIs(t, err, syscall.EINTR)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("EINTR of a blocking lock is returned", func(t *testing.T) {
+		var calls int
+		defer injectFcntl(func(fd uintptr, cmd int, lk *unix.Flock_t) error {
+			if cmd == unix.F_SETLKW {
+				calls++
+				return syscall.EINTR
+			}
+			return unix.FcntlFlock(fd, cmd, lk)
+		})()
+		l := New(lockfile).(*Lock)
+		require.ErrorIs(t, l.Lock(), syscall.EINTR)
+		require.Equal(t, 1, calls)
+		require.False(t, l.HeldByMe())
+	})
+}
+
+func TestInjectedOpenErrno(t *testing.T) {
+	lockDir, cleanup := testhelper.Tempdir(t)
+	defer cleanup()
+	lockfile := filepath.Join(lockDir, "lck")
+
+	for _, errno := range []syscall.Errno{syscall.EACCES, syscall.EPERM, syscall.EROFS} {
+		errno := errno
+		t.Run("fail fast on "+errno.Error(), func(t *testing.T) {
+			var calls int
+			defer failingOpen(errno, &calls)()
+			l := New(lockfile)
+			require.ErrorIs(t, l.TryLock(), errno)
+
+			t1 := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+			defer cancel()
+			err := l.LockContext(ctx, 25*time.Millisecond)
+			require.ErrorIs(t, err, errno)
+			require.Contains(t, err.Error(), lockfile)
+			require.Less(t, int64(time.Since(t1)), int64(30*time.Millisecond))
+			require.Equal(t, 2, calls)
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package fcntllock
+
+import (
+	"syscall"
+)
+
+// Fcntl runs the fcntl lock command cmd, like syscall.F_GETLK, with ft on
+// the lock file descriptor, opening the lock file if needed. ft is updated
+// by the command, like the conflicting lock of a F_GETLK probe.
+//
+// Fcntl is an escape hatch for experts: it bypasses the lock state tracking,
+// so a lock set or released by Fcntl is not seen by HeldByMe, the metadata,
+// the hooks or the metrics.
+func (lck *Lock) Fcntl(cmd int, ft *syscall.Flock_t) error {
+	if err := lck.createLockDir(); err != nil {
+		return err
+	}
+	if lck.ReadWriteSeekCloser == nil {
+		if err := lck.open(); err != nil {
+			return wrapPathErr(lck.path, "open", err)
+		}
+	}
+	return wrapPathErr(lck.path, "fcntl", syscall.FcntlFlock(lck.fd, cmd, ft))
+}
@@ -0,0 +1,120 @@
+package fcntllock
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// fifoTicket is a contender registration in the WithFIFO sequence file,
+// held as a lock on the byte at offset 1+n of the file
+type fifoTicket struct {
+	file  *os.File
+	n     int64
+	begin time.Time
+}
+
+// WithFIFO makes the LockContext contenders of different processes acquire
+// the lock in their arrival order, using a "<path>.fifo" sidecar sequence
+// file: each contender takes the next sequence number, and only attempts the
+// real lock when no contender with a lower sequence number is still waiting.
+//
+// This is a best effort ordering:
+//   - Only the contenders using WithFIFO are ordered. The other lock
+//     attempts can still be granted first.
+//   - A contender is registered by a fcntl lock on the sidecar file, so its
+//     registration is released by the kernel if it crashes, and the next
+//     contenders are not blocked.
+//   - A contender waiting its turn for longer than skipAfter, for example
+//     behind a hung contender, attempts the real lock anyway.
+//   - The contenders of the same process don't see each other
+//     registrations, and their order is not guaranteed. Use
+//     AcquireWithPriority for the in-process fairness.
+func WithFIFO(skipAfter time.Duration) Option {
+	return func(lck *Lock) {
+		lck.fifoSkipAfter = skipAfter
+	}
+}
+
+func (lck *Lock) fifoPath() string {
+	return lck.path + ".fifo"
+}
+
+// fifoWait returns fn only attempted when the caller is the first
+// registered contender, and the func unregistering the caller, to call when
+// the wait is over
+func (lck *Lock) fifoWait(fn func() error) (ordered func() error, dequeue func(), err error) {
+	ticket, err := lck.fifoEnqueue()
+	if err != nil {
+		return nil, nil, err
+	}
+	ordered = func() error {
+		if time.Since(ticket.begin) < lck.fifoSkipAfter && !ticket.first() {
+			return ErrBusy
+		}
+		return fn()
+	}
+	dequeue = func() {
+		_ = ticket.file.Close()
+	}
+	return
+}
+
+// fifoEnqueue takes the next sequence number, and registers it
+func (lck *Lock) fifoEnqueue() (*fifoTicket, error) {
+	f, err := os.OpenFile(lck.fifoPath(), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	fd := f.Fd()
+	counterLock := &unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart, Len: 1}
+	if err := fcntlFlock(fd, unix.F_SETLKW, counterLock); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	ticket := &fifoTicket{file: f, begin: time.Now()}
+	err = func() error {
+		b := make([]byte, 32)
+		n, err := f.ReadAt(b, 0)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if s := strings.TrimSpace(string(b[:n])); s != "" {
+			if ticket.n, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return err
+			}
+		}
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := f.WriteAt([]byte(strconv.FormatInt(ticket.n+1, 10)+"\n"), 0); err != nil {
+			return err
+		}
+		return fcntlFlock(fd, unix.F_SETLK, &unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart, Start: 1 + ticket.n, Len: 1})
+	}()
+	counterLock.Type = unix.F_UNLCK
+	_ = fcntlFlock(fd, unix.F_SETLK, counterLock)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	lck.logf("fifo sequence %d", ticket.n)
+	return ticket, nil
+}
+
+// first returns true if no contender with a lower sequence number is still
+// registered. A registration check failure doesn't block the caller.
+func (t *fifoTicket) first() bool {
+	if t.n == 0 {
+		return true
+	}
+	ft := &unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart, Start: 1, Len: t.n}
+	if err := fcntlFlock(t.file.Fd(), unix.F_GETLK, ft); err != nil {
+		return true
+	}
+	return ft.Type == unix.F_UNLCK
+}
@@ -0,0 +1,23 @@
+package fcntllock
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Filesystem returns the filesystem type of the lock path, and whether it is
+// a known network filesystem, where the fcntl locking semantics may differ.
+//
+// The lock directory filesystem is returned if the lock file doesn't exist
+// yet.
+func (lck *Lock) Filesystem() (fstype string, isNetwork bool, err error) {
+	path := lck.path
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path = filepath.Dir(path)
+	}
+	if fstype, err = filesystemType(path); err != nil {
+		return
+	}
+	isNetwork = networkFilesystems[fstype]
+	return
+}
@@ -0,0 +1,35 @@
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+package fcntllock
+
+import (
+	"syscall"
+)
+
+var (
+	// statfs is syscall.Statfs, replaced by tests to simulate filesystems
+	statfs = syscall.Statfs
+
+	networkFilesystems = map[string]bool{
+		"afpfs":  true,
+		"nfs":    true,
+		"smbfs":  true,
+		"webdav": true,
+	}
+)
+
+func filesystemType(path string) (string, error) {
+	var st syscall.Statfs_t
+	if err := statfs(path, &st); err != nil {
+		return "", err
+	}
+	b := make([]byte, 0, len(st.Fstypename))
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	return string(b), nil
+}
@@ -0,0 +1,57 @@
+package fcntllock
+
+import (
+	"fmt"
+	"syscall"
+)
+
+var (
+	// statfs is syscall.Statfs, replaced by tests to simulate filesystems
+	statfs = syscall.Statfs
+
+	// filesystemMagics maps the statfs magic numbers to filesystem types
+	filesystemMagics = map[int64]string{
+		0x0000517b: "smb",
+		0x00c36400: "ceph",
+		0x01021994: "tmpfs",
+		0x01021997: "9p",
+		0x0bd00bd0: "lustre",
+		0x2fc12fc1: "zfs",
+		0x5346414f: "afs",
+		0x58465342: "xfs",
+		0x564c:     "ncp",
+		0x6969:     "nfs",
+		0x65735546: "fuse",
+		0x73757245: "coda",
+		0x794c7630: "overlay",
+		0x9123683e: "btrfs",
+		0xef53:     "ext4",
+		0xfe534d42: "smb2",
+		0xff534d42: "cifs",
+	}
+
+	networkFilesystems = map[string]bool{
+		"9p":     true,
+		"afs":    true,
+		"ceph":   true,
+		"cifs":   true,
+		"coda":   true,
+		"lustre": true,
+		"ncp":    true,
+		"nfs":    true,
+		"smb":    true,
+		"smb2":   true,
+	}
+)
+
+func filesystemType(path string) (string, error) {
+	var st syscall.Statfs_t
+	if err := statfs(path, &st); err != nil {
+		return "", err
+	}
+	magic := int64(st.Type) & 0xffffffff
+	if fstype, ok := filesystemMagics[magic]; ok {
+		return fstype, nil
+	}
+	return fmt.Sprintf("0x%x", magic), nil
+}
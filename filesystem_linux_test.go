@@ -0,0 +1,36 @@
+package fcntllock
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesystemStubbedStatfs(t *testing.T) {
+	defer func() { statfs = syscall.Statfs }()
+	cases := []struct {
+		magic     int64
+		fstype    string
+		isNetwork bool
+	}{
+		{0x6969, "nfs", true},
+		{0xff534d42, "cifs", true},
+		{0xfe534d42, "smb2", true},
+		{0xef53, "ext4", false},
+		{0x01021994, "tmpfs", false},
+		{0x1234, "0x1234", false},
+	}
+	for _, c := range cases {
+		statfs = func(path string, st *syscall.Statfs_t) error {
+			// the Type field size is platform dependent
+			reflect.ValueOf(&st.Type).Elem().SetInt(c.magic)
+			return nil
+		}
+		fstype, isNetwork, err := New("/var/lock/lck").(*Lock).Filesystem()
+		require.NoError(t, err)
+		require.Equal(t, c.fstype, fstype)
+		require.Equal(t, c.isNetwork, isNetwork)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package fcntllock
+
+var networkFilesystems = map[string]bool{}
+
+func filesystemType(string) (string, error) {
+	return "", ErrNotSupported
+}
@@ -0,0 +1,95 @@
+package fcntllock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type (
+	// FlockOpts are the RunWithLock options, mirroring the flock(1) command
+	// options
+	FlockOpts struct {
+		// Shared acquires a shared lock instead of the default exclusive
+		// lock (flock -s)
+		Shared bool
+
+		// NonBlock fails instead of waiting if the lock can't be
+		// acquired immediately (flock -n)
+		NonBlock bool
+
+		// Timeout fails if the lock can't be acquired within Timeout
+		// (flock -w). Zero means wait forever.
+		Timeout time.Duration
+
+		// ConflictExitCode is the ConflictError exit code, defaults to 1
+		// (flock -E)
+		ConflictExitCode int
+	}
+
+	// ConflictError is returned by RunWithLock when the lock is held by
+	// someone else, and NonBlock is set or Timeout is reached
+	ConflictError struct {
+		// ExitCode is the flock(1) -E exit code
+		ExitCode int
+
+		// Err is the lock error
+		Err error
+	}
+)
+
+// Error implements error
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("lock conflict (exit code %d): %s", e.ExitCode, e.Err)
+}
+
+// Unwrap returns the lock error
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// RunWithLock runs fn while holding the lock, with the flock(1) command
+// semantics.
+//
+// fn error is returned. The lock is released after fn returns.
+func (lck *Lock) RunWithLock(opts FlockOpts, fn func() error) error {
+	typ := Exclusive
+	if opts.Shared {
+		typ = Shared
+	}
+	if err := lck.acquireFlock(typ, opts); err != nil {
+		return err
+	}
+	defer func() { _ = lck.UnLock() }()
+	return fn()
+}
+
+func (lck *Lock) acquireFlock(typ LockType, opts FlockOpts) error {
+	var err error
+	exitCode := opts.ConflictExitCode
+	if exitCode == 0 {
+		exitCode = 1
+	}
+	if err = lck.createLockDir(); err != nil {
+		return err
+	}
+	switch {
+	case opts.NonBlock:
+		err = lck.lockAs(typ, false)
+		if lck.isContended(err) {
+			countContention()
+			return &ConflictError{ExitCode: exitCode, Err: err}
+		}
+	case opts.Timeout > 0:
+		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+		defer cancel()
+		err = lck.lockWait(ctx, typ)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return &ConflictError{ExitCode: exitCode, Err: err}
+		}
+	default:
+		err = lck.lockWait(context.Background(), typ)
+	}
+	return err
+}
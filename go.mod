@@ -6,4 +6,5 @@ require (
 	github.com/opensvc/locker v1.0.3
 	github.com/opensvc/testhelper v1.0.0
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/sys v0.0.0-20210423082822-04245dca01da
 )
@@ -0,0 +1,56 @@
+package fcntllock
+
+import (
+	"io"
+	"sync"
+)
+
+type lockHandle struct {
+	lck  *Lock
+	once sync.Once
+}
+
+// Handle returns an io.Closer whose Close releases the lock and closes the
+// lock file. Close can be called several times, only the first call has
+// effect.
+func (lck *Lock) Handle() io.Closer {
+	return &lockHandle{lck: lck}
+}
+
+func (h *lockHandle) Close() (err error) {
+	h.once.Do(func() {
+		err = h.lck.UnLock()
+		if cerr := h.lck.closeFile(); err == nil {
+			err = cerr
+		}
+	})
+	return
+}
+
+// requireFile returns ErrNoFd if the lock is held without lock file, like
+// with a lock backend, so the lock file operations have no descriptor to
+// work on
+func (lck *Lock) requireFile() error {
+	if lck.backend != nil || lck.ReadWriteSeekCloser == nil {
+		return ErrNoFd
+	}
+	return nil
+}
+
+// closeFile closes the lock file, so the next lock opens it again
+func (lck *Lock) closeFile() error {
+	if lck.ReadWriteSeekCloser == nil {
+		return nil
+	}
+	lck.unregisterFile()
+	var err error
+	if !lck.deferClose(lck.ReadWriteSeekCloser) {
+		err = lck.ReadWriteSeekCloser.Close()
+	}
+	lck.ReadWriteSeekCloser = nil
+	if lck.fdCounted {
+		lck.fdCounted = false
+		releaseFd()
+	}
+	return err
+}
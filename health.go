@@ -0,0 +1,37 @@
+package fcntllock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// HealthCheck verifies dir supports the fcntl locks: it creates a temporary
+// lock file in dir, acquires and releases its lock, then removes it. The
+// temporary lock file is removed even if a step fails.
+//
+// It is suitable for a service startup self check or readiness probe.
+func HealthCheck(dir string) (err error) {
+	f, err := ioutil.TempFile(dir, ".fcntllock-health-")
+	if err != nil {
+		return fmt.Errorf("fcntllock health check %s: create lock file: %w", dir, err)
+	}
+	path := f.Name()
+	defer func() {
+		if rerr := os.Remove(path); rerr != nil && err == nil {
+			err = fmt.Errorf("fcntllock health check %s: remove lock file: %w", dir, rerr)
+		}
+	}()
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("fcntllock health check %s: close lock file: %w", dir, err)
+	}
+	lck := New(path).(*Lock)
+	defer func() { _ = lck.Close() }()
+	if err := lck.TryLock(); err != nil {
+		return fmt.Errorf("fcntllock health check %s: acquire: %w", dir, err)
+	}
+	if err := lck.UnLock(); err != nil {
+		return fmt.Errorf("fcntllock health check %s: release: %w", dir, err)
+	}
+	return nil
+}
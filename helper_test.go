@@ -0,0 +1,66 @@
+package fcntllock
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// lockInFork starts a process holding the lock on path during 102
+// milliseconds, and returns once the lock is held
+func lockInFork(t *testing.T, path string) *exec.Cmd {
+	t.Helper()
+	return lockInForkFor(t, path, 102*time.Millisecond)
+}
+
+// lockInForkFor starts a process holding the lock on path during hold, and
+// returns once the lock is held. The process writes to the ready pipe, its
+// fd 3, once the lock is acquired.
+func lockInForkFor(t *testing.T, path string, hold time.Duration) *exec.Cmd {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("create ready pipe: %s", err)
+	}
+	defer func() { _ = r.Close() }()
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess", "--", hold.String(), path)
+	// the race detector delays the exit, so the lock release, by 1s
+	cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1", "GORACE=atexit_sleep_ms=0"}
+	cmd.ExtraFiles = []*os.File{w}
+	err = cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		t.Fatalf("start lock helper process: %s", err)
+	}
+	if _, err := r.Read(make([]byte, 1)); err != nil {
+		_ = cmd.Wait()
+		t.Fatalf("lock helper process not ready: %s", err)
+	}
+	return cmd
+}
+
+// tryLockInFork runs a process trying the lock on path, and returns its exit
+// error: nil if the lock was acquired
+func tryLockInFork(path string) error {
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess", "--", "0s", path)
+	cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1", "GORACE=atexit_sleep_ms=0"}
+	return cmd.Run()
+}
+
+func TestHelperProcess(t *testing.T) {
+	t.Helper()
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	hold, _ := time.ParseDuration(os.Args[len(os.Args)-2])
+	path := os.Args[len(os.Args)-1]
+	if err := New(path).TryLock(); err != nil {
+		os.Exit(1)
+	}
+	ready := os.NewFile(3, "ready")
+	_, _ = ready.Write([]byte{0})
+	_ = ready.Close()
+	time.Sleep(hold)
+	os.Exit(0)
+}
@@ -0,0 +1,61 @@
+package fcntllock
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InfBucket is the WaitHistogram key counting the waits longer than the
+// largest bucket.
+const InfBucket = time.Duration(math.MaxInt64)
+
+type waitHistogram struct {
+	sync.Mutex
+	buckets []time.Duration
+	counts  []int
+}
+
+// WithWaitBuckets records the successful LockContext wait durations into
+// buckets.
+//
+// A wait is counted in the smallest bucket greater or equal to the wait
+// duration, or in InfBucket. See WaitHistogram.
+func WithWaitBuckets(buckets []time.Duration) Option {
+	return func(lck *Lock) {
+		b := append([]time.Duration{}, buckets...)
+		sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+		lck.waits = &waitHistogram{
+			buckets: b,
+			counts:  make([]int, len(b)+1),
+		}
+	}
+}
+
+// WaitHistogram returns the wait counts per bucket, or nil if WithWaitBuckets
+// is not used.
+func (lck *Lock) WaitHistogram() map[time.Duration]int {
+	if lck.waits == nil {
+		return nil
+	}
+	return lck.waits.snapshot()
+}
+
+func (h *waitHistogram) record(d time.Duration) {
+	i := sort.Search(len(h.buckets), func(i int) bool { return h.buckets[i] >= d })
+	h.Lock()
+	h.counts[i]++
+	h.Unlock()
+}
+
+func (h *waitHistogram) snapshot() map[time.Duration]int {
+	h.Lock()
+	defer h.Unlock()
+	m := make(map[time.Duration]int, len(h.counts))
+	for i, b := range h.buckets {
+		m[b] = h.counts[i]
+	}
+	m[InfBucket] = h.counts[len(h.buckets)]
+	return m
+}
@@ -0,0 +1,53 @@
+package fcntllock
+
+import (
+	"os"
+)
+
+// SignalHolder sends sig to the process holding the lock of path, for the
+// operator driven recovery of a stuck lock: a fcntl lock can't be released
+// by another process, but its holder can be asked to exit, or killed.
+//
+// The holder pid is the F_GETLK lock owner, or else, like for OFD locks, the
+// WithMetadata pid if the metadata host is the local host. ErrNoHolder is
+// returned if the lock is free, and ErrHolderUnknown if the holder pid can't
+// be determined. Only the locks of the other processes are seen.
+func SignalHolder(path string, sig os.Signal) error {
+	pid, held, err := holderPID(path)
+	switch {
+	case err != nil:
+		return err
+	case !held:
+		return ErrNoHolder
+	case pid <= 0:
+		return ErrHolderUnknown
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
+
+// holderPID returns the pid of the lock holder of path, from the fcntl lock
+// or else from the holder metadata written on this host, or 0 if unknown.
+// held is false if the lock is free.
+func holderPID(path string) (pid int, held bool, err error) {
+	status, err := Probe(path)
+	if err != nil || !status.Held {
+		return 0, false, err
+	}
+	if status.PID > 0 {
+		return status.PID, true, nil
+	}
+	m, err := ReadMetadata(path)
+	if err != nil {
+		return 0, true, nil
+	}
+	// the pid of a holder on another host, sharing the lock file through a
+	// network filesystem, is not a local process
+	if host, err := os.Hostname(); err != nil || m.Host != host {
+		return 0, true, nil
+	}
+	return m.PID, true, nil
+}
@@ -0,0 +1,29 @@
+package fcntllock
+
+import (
+	"unicode"
+)
+
+// WithIdentity records id, the application level identity of the lock
+// holder, like a tenant id or a service name, in the lock file Metadata
+// along the holder pid, so the audits can attribute the lock to a logical
+// actor. The metadata is written even without WithMetadata.
+//
+// id must not contain newlines or other control characters, breaking the
+// metadata format, or the lock attempts fail with ErrInvalidIdentity.
+func WithIdentity(id string) Option {
+	return func(lck *Lock) {
+		lck.identity = id
+	}
+}
+
+// checkIdentity returns ErrInvalidIdentity if the WithIdentity identity
+// contains control characters
+func (lck *Lock) checkIdentity() error {
+	for _, r := range lck.identity {
+		if unicode.IsControl(r) {
+			return ErrInvalidIdentity
+		}
+	}
+	return nil
+}
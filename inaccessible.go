@@ -0,0 +1,58 @@
+package fcntllock
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// InaccessiblePolicy tells how a lock file that exists but can't be opened
+// for permission reasons is reported. Such a lock file may be held by its
+// owner, but it can't be probed.
+type InaccessiblePolicy int
+
+const (
+	// InaccessibleAsPermissionError reports the open permission error as
+	// is. It is the default.
+	InaccessibleAsPermissionError InaccessiblePolicy = iota
+
+	// InaccessibleAnnotate annotates the open permission error with the
+	// lock file owner, as a possible lock holder.
+	InaccessibleAnnotate
+
+	// InaccessibleAsError reports an error matching both
+	// ErrLockFileInaccessible and the open permission error.
+	InaccessibleAsError
+)
+
+// WithInaccessiblePolicy sets how a lock file that exists but can't be
+// opened for permission reasons is reported, to tell it apart from a
+// permission error on a missing lock file, like a non writable lock
+// directory.
+func WithInaccessiblePolicy(policy InaccessiblePolicy) Option {
+	return func(lck *Lock) {
+		lck.inaccessiblePolicy = policy
+	}
+}
+
+// inaccessibleErr returns the open permission error err, as reported by the
+// inaccessible lock file policy
+func (lck *Lock) inaccessibleErr(err error) error {
+	if lck.inaccessiblePolicy == InaccessibleAsPermissionError {
+		return err
+	}
+	info, serr := stat(lck.path)
+	if serr != nil {
+		// not an existing lock file
+		return err
+	}
+	switch lck.inaccessiblePolicy {
+	case InaccessibleAnnotate:
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			return fmt.Errorf("%w (lock file exists but is not accessible, it may be held by a process of uid %d)", err, st.Uid)
+		}
+		return fmt.Errorf("%w (lock file exists but is not accessible, it may be held)", err)
+	case InaccessibleAsError:
+		return &sentinelError{sentinel: ErrLockFileInaccessible, err: err}
+	}
+	return err
+}
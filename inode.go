@@ -0,0 +1,85 @@
+package fcntllock
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// fileID identifies a file by its device and inode numbers
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// WithInodeCheck makes the lock record the device and inode numbers of the
+// lock file on acquisition, and makes UnLock and Verify fail with an error
+// matching ErrLockFileReplaced if the lock path no longer points to this
+// file, for example after a rotation replacing the lock file while held.
+//
+// The replaced lock file is still released by UnLock.
+func WithInodeCheck(v bool) Option {
+	return func(lck *Lock) {
+		lck.inodeCheck = v
+	}
+}
+
+// Verify returns an error matching ErrLockFileReplaced if the lock path no
+// longer points to the locked file, and ErrNotLocked if the lock is not held
+// by lck.
+//
+// The locked file is the file recorded on acquisition when WithInodeCheck is
+// set, else the file of the lock file descriptor.
+func (lck *Lock) Verify() error {
+	if !lck.held {
+		return ErrNotLocked
+	}
+	return wrapPathErr(lck.path, "verify", lck.verifyInode())
+}
+
+// recordInode records the locked file id, if WithInodeCheck is set
+func (lck *Lock) recordInode() error {
+	if !lck.inodeCheck || lck.backend != nil {
+		return nil
+	}
+	id, err := fdFileID(lck.fd)
+	if err != nil {
+		return err
+	}
+	lck.inode = &id
+	return nil
+}
+
+func (lck *Lock) verifyInode() error {
+	if lck.backend != nil {
+		// no lock file
+		return nil
+	}
+	locked := lck.inode
+	if locked == nil {
+		id, err := fdFileID(lck.fd)
+		if err != nil {
+			return err
+		}
+		locked = &id
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(lck.path, &st); err != nil {
+		return &sentinelError{sentinel: ErrLockFileReplaced, err: err}
+	}
+	if id := (fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}); id != *locked {
+		return &sentinelError{
+			sentinel: ErrLockFileReplaced,
+			err:      fmt.Errorf("inode %d:%d replaced by %d:%d", locked.dev, locked.ino, id.dev, id.ino),
+		}
+	}
+	return nil
+}
+
+// fdFileID returns the file id of the file open as fd
+func fdFileID(fd uintptr) (fileID, error) {
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(fd), &st); err != nil {
+		return fileID{}, err
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, nil
+}
@@ -0,0 +1,68 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+// +build linux,!mips,!mipsle,!mips64,!mips64le
+
+package fcntllock
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// interruptSignal is the real-time signal sent to the thread blocked in a
+// LockWait fcntl call to interrupt it. The Go runtime handler of the signal
+// ignores it, unless the application asks for its notification.
+const interruptSignal = syscall.Signal(63)
+
+const (
+	sigDfl    = 0
+	sigIgn    = 1
+	saRestart = 0x10000000
+)
+
+// sigaction is the kernel struct sigaction, only the handler and flags are
+// used: the rest covers the restorer and the mask, whatever the layout.
+type sigaction struct {
+	handler  uintptr
+	flags    uintptr
+	reserved [3]uint64
+}
+
+// prepareInterrupt ensures the interruptSignal handler doesn't restart the
+// interrupted system calls, as the Go runtime handlers do. It returns
+// ErrNotSupported if the signal is not handled by the Go runtime, like when
+// the application ignores it.
+func prepareInterrupt() error {
+	var sa sigaction
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_RT_SIGACTION, uintptr(interruptSignal), 0, uintptr(unsafe.Pointer(&sa)), 8, 0, 0); errno != 0 {
+		return errno
+	}
+	if sa.handler == sigDfl || sa.handler == sigIgn {
+		return ErrNotSupported
+	}
+	if sa.flags&saRestart == 0 {
+		return nil
+	}
+	sa.flags &^= saRestart
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_RT_SIGACTION, uintptr(interruptSignal), uintptr(unsafe.Pointer(&sa)), 0, 8, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// gettid returns the id of the calling thread
+func gettid() int {
+	return unix.Gettid()
+}
+
+// interruptThread interrupts the system call of the thread tid with
+// interruptSignal. The signal is not sent if its handler no longer
+// prevents the system call restart.
+func interruptThread(tid int) error {
+	if err := prepareInterrupt(); err != nil {
+		return err
+	}
+	return unix.Tgkill(os.Getpid(), tid, interruptSignal)
+}
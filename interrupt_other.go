@@ -0,0 +1,18 @@
+//go:build !linux || mips || mipsle || mips64 || mips64le
+// +build !linux mips mipsle mips64 mips64le
+
+package fcntllock
+
+// prepareInterrupt returns ErrNotSupported: the blocked thread interruption
+// is linux only
+func prepareInterrupt() error {
+	return ErrNotSupported
+}
+
+func gettid() int {
+	return 0
+}
+
+func interruptThread(int) error {
+	return ErrNotSupported
+}
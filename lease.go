@@ -0,0 +1,89 @@
+package fcntllock
+
+import (
+	"sync"
+	"time"
+)
+
+// minLeaseRenew is the minimal interval of the lease renewals
+const minLeaseRenew = time.Millisecond
+
+type lease struct {
+	ttl   time.Duration
+	renew time.Duration
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// WithLease makes the lock holder a renewable lease: while the lock is held,
+// a background renewer updates the Renewed metadata every third of ttl.
+//
+// This is a cooperative layer on top of the kernel lock: observers reading the
+// metadata consider the lease expired if the holder fails to renew it within
+// ttl, see LeaseExpired. WithLease implies WithMetadata.
+//
+// A ttl <= 0 disables the lease. The renewals are at least 1ms apart.
+func WithLease(ttl time.Duration) Option {
+	return func(lck *Lock) {
+		if ttl <= 0 {
+			lck.lease = nil
+			return
+		}
+		renew := ttl / 3
+		if renew < minLeaseRenew {
+			renew = minLeaseRenew
+		}
+		lck.metadata = true
+		lck.lease = &lease{ttl: ttl, renew: renew}
+	}
+}
+
+// LeaseExpired reads the lock file metadata and returns true if the holder
+// lease has not been renewed within its ttl. It returns false if the lock
+// file has no lease.
+//
+// The metadata is read through the lock file descriptor when the lock file
+// is open, so a holder checking its own lease keeps its lock.
+func (lck *Lock) LeaseExpired() bool {
+	m, err := lck.readMetadata()
+	if err != nil || m.Lease <= 0 {
+		return false
+	}
+	return time.Since(m.Renewed) > m.Lease
+}
+
+// startLease starts the lease renewer of the acquired lock
+func (lck *Lock) startLease() {
+	l := lck.lease
+	l.stop = make(chan struct{})
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		ticker := time.NewTicker(l.renew)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-lck.baseDone():
+				return
+			case <-ticker.C:
+				renew := func(m *Metadata) { m.Renewed = time.Now() }
+				if err := lck.writeMetadata(renew); err != nil {
+					lck.logf("lease renew: %s", err)
+				}
+			}
+		}
+	}()
+}
+
+// stopLease stops the lease renewer, if running
+func (lck *Lock) stopLease() {
+	l := lck.lease
+	if l == nil || l.stop == nil {
+		return
+	}
+	close(l.stop)
+	l.wg.Wait()
+	l.stop = nil
+}
@@ -0,0 +1,55 @@
+package fcntllock
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// LockAll acquires locks on all paths, or none.
+//
+// Locks are acquired in sorted path order, so concurrent LockAll calls on the
+// same set of paths can't deadlock whatever the requested order. On any
+// failure the already acquired locks are released and the error is returned.
+//
+// The paths are compared after normalization, so the paths resolving to the
+// same file, like a symlink and its target, are locked once.
+//
+// The returned unlock function releases all the acquired locks.
+func LockAll(ctx context.Context, retryDelay time.Duration, paths ...string) (unlock func() error, err error) {
+	sorted := make([]string, 0, len(paths))
+	byKey := make(map[string]string)
+	for _, p := range paths {
+		key := registryKey(p)
+		if _, ok := byKey[key]; ok {
+			continue
+		}
+		byKey[key] = p
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	locks := make([]Locker, 0, len(sorted))
+	unlock = func() error {
+		var errs error
+		for i := len(locks) - 1; i >= 0; i-- {
+			if err := locks[i].UnLock(); err != nil && errs == nil {
+				errs = err
+			}
+			if err := locks[i].Close(); err != nil && errs == nil {
+				errs = err
+			}
+		}
+		locks = locks[:0]
+		return errs
+	}
+	for _, key := range sorted {
+		l := New(byKey[key])
+		if err = l.LockContext(ctx, retryDelay); err != nil {
+			_ = unlock()
+			return nil, err
+		}
+		locks = append(locks, l)
+	}
+	return unlock, nil
+}
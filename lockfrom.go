@@ -0,0 +1,48 @@
+package fcntllock
+
+import (
+	"io"
+
+	"golang.org/x/sys/unix"
+)
+
+// LockFrom acquires an exclusive write lock on length bytes from the current
+// file offset (non blocking). A zero length locks up to the end of file,
+// whatever the file growth.
+//
+// The current file offset is the offset of the embedded ReadWriteSeekCloser,
+// moved by its Read, Write and Seek calls. LockFrom doesn't move it. UnLock
+// releases the range.
+//
+// The range locks are fcntl locks: LockFrom returns ErrNotSupported with a
+// lock backend.
+func (lck *Lock) LockFrom(length int64) error {
+	if err := lck.createLockDir(); err != nil {
+		return err
+	}
+	if err := lck.checkSetLock(); err != nil {
+		return wrapPathErr(lck.path, "lock", err)
+	}
+	if lck.backend != nil {
+		return wrapPathErr(lck.path, "lock", ErrNotSupported)
+	}
+	if lck.ReadWriteSeekCloser == nil {
+		if err := lck.open(); err != nil {
+			return wrapPathErr(lck.path, "open", err)
+		}
+	}
+	start, err := lck.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return wrapPathErr(lck.path, "seek", err)
+	}
+	ft := &unix.Flock_t{
+		Start:  start,
+		Len:    length,
+		Type:   unix.F_WRLCK,
+		Whence: io.SeekStart,
+	}
+	if err := lck.setFcntlRange(ft, false); err != nil {
+		return wrapPathErr(lck.path, "lock", err)
+	}
+	return wrapPathErr(lck.path, "lock", lck.setHeld(Exclusive, start, length))
+}
@@ -0,0 +1,126 @@
+package fcntllock
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// LockType is the type of a fcntl lock
+type LockType int16
+
+const (
+	// Exclusive is the write lock type, conflicting with any other lock
+	Exclusive LockType = unix.F_WRLCK
+
+	// Shared is the read lock type, only conflicting with Exclusive locks
+	Shared LockType = unix.F_RDLCK
+)
+
+// String implements fmt.Stringer
+func (t LockType) String() string {
+	switch t {
+	case Exclusive:
+		return "exclusive"
+	case Shared:
+		return "shared"
+	default:
+		return "unknown"
+	}
+}
+
+// LockType returns the type of the lock held by lck, and false if the lock
+// is not held.
+func (lck *Lock) LockType() (LockType, bool) {
+	return lck.heldType, lck.held
+}
+
+// TryRLock acquires a shared read file lock (non blocking)
+func (lck *Lock) TryRLock() error {
+	if err := lck.createLockDir(); err != nil {
+		return err
+	}
+	return lck.lockAs(Shared, false)
+}
+
+// RLockContext repeat TryRLock with retry delay until succeed or context Done
+func (lck *Lock) RLockContext(ctx context.Context, retryDelay time.Duration) error {
+	if err := lck.createLockDir(); err != nil {
+		return err
+	}
+	return lck.lockContext(ctx, lck.TryRLock, retryDelay)
+}
+
+// AcquireBest acquires a lock of type prefer, or a weaker lock if prefer is
+// not available, repeating with retry delay until succeed or context Done.
+//
+// The fallback policy is: an Exclusive lock attempt blocked by other lock
+// holders falls back to a Shared lock attempt, which succeeds if the other
+// holders only hold Shared locks. A Shared lock has no weaker fallback.
+//
+// got is the type of the acquired lock.
+func (lck *Lock) AcquireBest(ctx context.Context, prefer LockType, retryDelay time.Duration) (got LockType, err error) {
+	if err = lck.createLockDir(); err != nil {
+		return
+	}
+	fn := func() error {
+		got = prefer
+		err := lck.lockAs(prefer, false)
+		if prefer == Exclusive && lck.isContended(err) {
+			countContention()
+			got = Shared
+			err = lck.lockAs(Shared, false)
+		}
+		return err
+	}
+	err = lck.lockContext(ctx, fn, retryDelay)
+	return
+}
+
+// Upgrade converts the held Shared lock into an Exclusive lock, repeating
+// with retry delay until succeed or context Done.
+//
+// The fcntl conversion is atomic: a failed attempt keeps the Shared lock
+// held, so no other writer can get the lock during the conversion, and a
+// context Done leaves lck holding the Shared lock. The attempts are non
+// blocking, as a blocking conversion fails with EDEADLK when two readers
+// upgrade at once.
+//
+// The classic fcntl lock is owned by the process, so the conversion also
+// applies to the Shared locks of the other Lock values of the process on the
+// same file. Use WithOFD for a conversion limited to lck.
+//
+// Upgrade returns ErrNotLocked if the lock is not held by lck, and nil if
+// the held lock is already Exclusive.
+func (lck *Lock) Upgrade(ctx context.Context, retryDelay time.Duration) error {
+	if !lck.held {
+		return ErrNotLocked
+	}
+	if lck.heldType == Exclusive {
+		return nil
+	}
+	ctx, cancel := lck.withBase(ctx)
+	defer cancel()
+	if lck.reassert != nil && lck.backend == nil {
+		// the verifier re-issues the held lock type
+		lck.stopReassert()
+		defer lck.startReassert()
+	}
+	err := lck.try(ctx, func() error {
+		return wrapPathErr(lck.path, "lock", lck.convert(Exclusive))
+	}, retryDelay)
+	if err != nil {
+		return err
+	}
+	lck.heldType = Exclusive
+	return nil
+}
+
+// convert converts the held lock into a lock of type typ
+func (lck *Lock) convert(typ LockType) error {
+	if lck.backend != nil {
+		return lck.backend.TryAcquire(lck, typ)
+	}
+	return lck.fcntl(unix.F_SETLK, wholeFileLock(int16(typ)))
+}
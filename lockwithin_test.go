@@ -0,0 +1,47 @@
+package fcntllock
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+// slowStat makes stat sleep d before returning, until the returned restore
+// func is called
+func slowStat(d time.Duration) (restore func()) {
+	stat = func(name string) (os.FileInfo, error) {
+		time.Sleep(d)
+		return os.Stat(name)
+	}
+	return func() { stat = os.Stat }
+}
+
+func TestLockWithin(t *testing.T) {
+	t.Run("budget starts after the slow setup", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		forkCmd := lockInForkFor(t, lockfile, 350*time.Millisecond)
+		defer slowStat(300 * time.Millisecond)()
+
+		// the forked lock is released at most 350ms after LockWithin call,
+		// and the slow setup ends ~300ms after LockWithin call, so the
+		// lock is granted within the budget only if the budget starts
+		// after the setup
+		require.NoError(t, New(lockfile).(*Lock).LockWithin(context.Background(), 10*time.Millisecond, 250*time.Millisecond))
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("budget exhausted", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		forkCmd := lockInForkFor(t, lockfile, 500*time.Millisecond)
+
+		err := New(lockfile).(*Lock).LockWithin(context.Background(), 10*time.Millisecond, 30*time.Millisecond)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NoError(t, forkCmd.Wait())
+	})
+}
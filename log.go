@@ -0,0 +1,38 @@
+package fcntllock
+
+import (
+	"context"
+	"time"
+)
+
+// Logger is the interface used to log the lock events. *log.Logger
+// implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger logs the lock events to logger
+func WithLogger(logger Logger) Option {
+	return func(lck *Lock) {
+		lck.logger = logger
+	}
+}
+
+// LockContextID is LockContext with a correlation id added to the log
+// entries emitted during the acquisition.
+func (lck *Lock) LockContextID(ctx context.Context, retryDelay time.Duration, id string) error {
+	lck.correlationID = id
+	defer func() { lck.correlationID = "" }()
+	return lck.LockContext(ctx, retryDelay)
+}
+
+func (lck *Lock) logf(format string, v ...interface{}) {
+	if lck.logger == nil {
+		return
+	}
+	prefix := "fcntllock " + lck.path
+	if lck.correlationID != "" {
+		prefix += " id=" + lck.correlationID
+	}
+	lck.logger.Printf(prefix+": "+format, v...)
+}
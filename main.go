@@ -9,10 +9,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/opensvc/locker"
+	"golang.org/x/sys/unix"
 )
 
 type (
@@ -23,88 +26,712 @@ type (
 		io.Closer
 	}
 
+	// FdReadWriteSeekCloser is a ReadWriteSeekCloser exposing the file
+	// descriptor needed by fcntl, like *os.File
+	FdReadWriteSeekCloser interface {
+		ReadWriteSeekCloser
+		Fd() uintptr
+	}
+
 	// Lock implement fcntl lock features
 	Lock struct {
 		path string
 		ReadWriteSeekCloser
 		fd uintptr
+
+		// backend is the lock backend, nil for the fcntl backend
+		backend Backend
+
+		// fdCounted is true when the lock file is counted in OpenFDs
+		fdCounted bool
+
+		// callerFile is true when the lock file is provided by the caller,
+		// so it is not closed on lock failure
+		callerFile bool
+
+		// held is true when the lock has been acquired and not yet released
+		held bool
+
+		// onceToken is the token of the held AcquireOnce acquisition
+		onceToken string
+
+		// localKey is the registry key of the in-process lock held by
+		// AcquireWithPriority
+		localKey string
+
+		waits *waitHistogram
+
+		logger        Logger
+		correlationID string
+
+		metrics       Metrics
+		dirSetupSpent time.Duration
+
+		// statsHeld is true when lck is counted in the held locks
+		statsHeld bool
+
+		adaptive *adaptiveDelay
+
+		// storedRetryDelay is the SetRetryDelay retry delay
+		storedRetryDelay time.Duration
+
+		regularFileOnly bool
+		refuseHardlinks bool
+		noFollow        bool
+		inodeCheck      bool
+		inode           *fileID
+		requireOwner    bool
+		ownerUID        int
+
+		noCreateDir          bool
+		ensureDirOnPermError bool
+		eaccesNotContention  bool
+		enolckRetries        int
+
+		reentrant bool
+
+		postAcquire func(*Lock) error
+
+		createTemplate  []byte
+		templatePending bool
+
+		noCloseOnExec  bool
+		truncateOnLock bool
+		minSize        int64
+		forceCopyUp    bool
+		atomicCreate   bool
+
+		baseCtx context.Context
+
+		// preempt is the LockContextPreempt channel of the pending
+		// acquisition
+		preempt <-chan struct{}
+
+		// openID is the id of the lock file registered in openFiles
+		openID *fileID
+
+		ofd      bool
+		heldType LockType
+
+		// heldStart and heldLen are the range of the held lock, 0 and 0
+		// for the whole file
+		heldStart int64
+		heldLen   int64
+
+		mode     Mode
+		reassert *reassert
+		orphan   *orphanWatch
+
+		inaccessiblePolicy InaccessiblePolicy
+
+		bestEffort       bool
+		bestEffortWarned bool
+
+		fcntlTimeout time.Duration
+		guard        *fcntlGuard
+
+		contentionCounter bool
+
+		slowWait *slowWait
+		sampling *contentionSampling
+
+		fifoSkipAfter time.Duration
+		initialSpin   int
+
+		metadata   bool
+		recordWait bool
+		metaMu     sync.Mutex
+		meta       *Metadata
+		lease      *lease
+
+		// reason is the LockReason reason of the pending or held
+		// acquisition
+		reason string
+
+		identity string
 	}
+
+	// Option configures a Lock created by New
+	Option func(*Lock)
+)
+
+// defaultENOLCKRetries is the default number of retries of a lock attempt
+// failed with ENOLCK, see WithENOLCKRetries
+const defaultENOLCKRetries = 5
+
+const (
+	// lockFileFlags are the lock file open flags.
+	// O_NONBLOCK prevents the open from hanging on special files
+	// O_CLOEXEC is explicit, whatever the runtime default, and cleared
+	// after open if WithCloseOnExec(false)
+	lockFileFlags = os.O_CREATE | os.O_RDWR | os.O_SYNC | syscall.O_NONBLOCK | syscall.O_CLOEXEC
+
+	// lockFilePerm is the lock file creation mode, before umask
+	lockFilePerm os.FileMode = 0666
 )
 
 var (
 	lockDirPerm os.FileMode = 0700
+
+	// stat is os.Stat, replaced by tests to simulate slow filesystems
+	stat = os.Stat
+
+	// mkdirAll is os.MkdirAll, replaced by tests to simulate concurrent
+	// lock directory creations
+	mkdirAll = os.MkdirAll
+
+	// pread is syscall.Pread, replaced by tests to simulate short reads
+	pread = syscall.Pread
+
+	// openFile is os.OpenFile, replaced by tests to simulate open errors
+	openFile = os.OpenFile
+
+	// fcntlFlock is unix.FcntlFlock, replaced by tests to simulate lock
+	// errors
+	fcntlFlock = unix.FcntlFlock
 )
 
 // New create a new fcntl lock
-func New(path string) Locker {
-	return &Lock{
-		path: path,
+func New(path string, opts ...Option) Locker {
+	lck := &Lock{}
+	lck.init(path, opts)
+	return lck
+}
+
+// init sets lck to a new lock of path, configured by opts
+func (lck *Lock) init(path string, opts []Option) {
+	*lck = Lock{
+		path:          path,
+		enolckRetries: defaultENOLCKRetries,
+	}
+	for _, opt := range opts {
+		opt(lck)
+	}
+}
+
+// WithSuffix appends suffix to the lock path, so callers can pass a base name
+// and get a conventionally named lock file, like "<base>.lock"
+func WithSuffix(suffix string) Option {
+	return func(lck *Lock) {
+		lck.path += suffix
 	}
 }
 
+// Path returns the effective lock file path
+func (lck *Lock) Path() string {
+	return lck.path
+}
+
 // TryLock acquires an exclusive write file lock (non blocking)
 func (lck *Lock) TryLock() error {
-	if err := createLockDir(lck.path); err != nil {
+	if err := lck.createLockDir(); err != nil {
 		return err
 	}
 	return lck.lock(false)
 }
 
+// LockWithin repeat TryLock with retry delay until succeed, ctx Done, or
+// budget is elapsed.
+//
+// Contrary to a LockContext with a timeout context, the budget starts once
+// the lock directory is ensured and the lock file is opened, so a slow setup
+// doesn't eat into the acquisition budget.
+func (lck *Lock) LockWithin(ctx context.Context, retryDelay, budget time.Duration) error {
+	if err := lck.createLockDir(); err != nil {
+		return err
+	}
+	if lck.ReadWriteSeekCloser == nil {
+		if err := lck.open(); err != nil {
+			return wrapPathErr(lck.path, "open", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+	return lck.lockContext(ctx, func() error { return lck.lock(false) }, retryDelay)
+}
+
+// LockBounded is LockContext that never waits more than hardMax, whatever the
+// ctx deadline.
+func (lck *Lock) LockBounded(ctx context.Context, retryDelay, hardMax time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, hardMax)
+	defer cancel()
+	return lck.LockContext(ctx, retryDelay)
+}
+
+// Lock acquires an exclusive write file lock, waiting for the lock release
+// (blocking).
+//
+// Lock returns ErrWouldSelfDeadlock when the lock is already held by lck,
+// unless WithReentrant is set.
+func (lck *Lock) Lock() error {
+	if lck.held && !lck.reentrant {
+		return ErrWouldSelfDeadlock
+	}
+	if err := lck.createLockDir(); err != nil {
+		return err
+	}
+	return lck.lock(true)
+}
+
 // UnLock release lock
-func (lck Lock) UnLock() (err error) {
-	ft := &syscall.Flock_t{
-		Start:  0,
-		Len:    0,
-		Pid:    0,
-		Type:   syscall.F_UNLCK,
-		Whence: io.SeekStart,
+func (lck *Lock) UnLock() (err error) {
+	var replaced error
+	if lck.inode != nil && lck.held {
+		replaced = lck.verifyInode()
+	}
+	lck.stopLease()
+	lck.stopReassert()
+	lck.stopOrphanWatch()
+	if err = lck.getBackend().Release(lck); err != nil && !lck.degraded(err) {
+		return wrapPathErr(lck.path, "unlock", err)
+	}
+	lck.held = false
+	lck.inode = nil
+	lck.countReleased()
+	lck.onceToken = ""
+	lck.reason = ""
+	lck.releaseLocalLock()
+	lck.logf("released")
+	if replaced != nil {
+		lck.logf("released lock file was replaced: %s", replaced)
+		return wrapPathErr(lck.path, "unlock", replaced)
 	}
-	err = syscall.FcntlFlock(lck.fd, syscall.F_SETLK, ft)
 	return
 }
 
+// UnLockResult release lock like UnLock, and reports if the lock was
+// actually held by lck before the release
+func (lck *Lock) UnLockResult() (released bool, err error) {
+	held := lck.held
+	if err = lck.UnLock(); err != nil {
+		return false, err
+	}
+	return held, nil
+}
+
+// WithPostAcquire runs fn right after the lock is acquired, before the lock
+// method returns. If fn returns an error, the lock is released and the error
+// is returned by the lock method.
+func WithPostAcquire(fn func(l *Lock) error) Option {
+	return func(lck *Lock) {
+		lck.postAcquire = fn
+	}
+}
+
+// WithReentrant allows Lock to be called again while the lock is already held
+// by the same Lock.
+func WithReentrant(v bool) Option {
+	return func(lck *Lock) {
+		lck.reentrant = v
+	}
+}
+
+// HeldByMe returns true when the lock has been acquired by lck and not yet
+// released
+func (lck *Lock) HeldByMe() bool {
+	return lck.held
+}
+
 // LockContext repeat TryLock with retry delay until succeed or context Done
 func (lck *Lock) LockContext(ctx context.Context, retryDelay time.Duration) error {
-	if err := createLockDir(lck.path); err != nil {
+	if err := lck.createLockDir(); err != nil {
+		return err
+	}
+	return lck.lockContext(ctx, lck.TryLock, retryDelay)
+}
+
+// LockContextStats is LockContext also returning the elapsed wait and the
+// number of lock attempts, on success or failure, to help tune the timeouts.
+func (lck *Lock) LockContextStats(ctx context.Context, retryDelay time.Duration) (waited time.Duration, attempts int, err error) {
+	begin := time.Now()
+	if err = lck.createLockDir(); err == nil {
+		err = lck.lockContext(ctx, func() error {
+			attempts++
+			return lck.TryLock()
+		}, retryDelay)
+	}
+	return time.Since(begin), attempts, err
+}
+
+// LockContextTick is LockContext calling onTick once per retry delay while
+// waiting for the lock, for example to update a progress UI. onTick is never
+// called after the acquisition or the ctx end.
+func (lck *Lock) LockContextTick(ctx context.Context, retryDelay time.Duration, onTick func()) error {
+	if err := lck.createLockDir(); err != nil {
 		return err
 	}
-	return lck.try(ctx, lck.TryLock, retryDelay)
+	return lck.lockContext(ctx, func() error {
+		err := lck.TryLock()
+		if lck.isContended(err) && ctx.Err() == nil {
+			onTick()
+		}
+		return err
+	}, retryDelay)
 }
 
-func (lck *Lock) lock(blocking bool) (err error) {
+// LockContextPreempt is LockContext also aborting the wait with
+// ErrPreempted when preempt is closed or receives, for example on an
+// application reload event.
+func (lck *Lock) LockContextPreempt(ctx context.Context, retryDelay time.Duration, preempt <-chan struct{}) error {
+	if err := lck.createLockDir(); err != nil {
+		return err
+	}
+	lck.preempt = preempt
+	defer func() { lck.preempt = nil }()
+	return lck.lockContext(ctx, lck.TryLock, retryDelay)
+}
+
+// LockWhile repeat TryLock with retry delay until succeed or context Done,
+// like LockContext, but aborts with ErrConditionFalse as soon as cond returns
+// false. cond is evaluated before each lock attempt.
+func (lck *Lock) LockWhile(ctx context.Context, retryDelay time.Duration, cond func() bool) error {
+	if err := lck.createLockDir(); err != nil {
+		return err
+	}
+	return lck.lockContext(ctx, func() error {
+		if !cond() {
+			return ErrConditionFalse
+		}
+		return lck.TryLock()
+	}, retryDelay)
+}
+
+// LockAndOpen acquires the lock like LockContext, and returns the lock file
+// for the caller to read and write the locked content directly.
+//
+// The returned file is owned by lck: the caller must release the lock with
+// UnLock, and must not close the file, as closing any descriptor of the lock
+// file releases the fcntl locks of the process. The file offset is not
+// reset by the acquisition, so the caller should Seek before reading.
+//
+// ErrNoFd is returned, and the lock released, when the lock is held without
+// lock file, like with a lock backend or a best effort noop lock.
+func (lck *Lock) LockAndOpen(ctx context.Context, retryDelay time.Duration) (ReadWriteSeekCloser, error) {
+	if err := lck.LockContext(ctx, retryDelay); err != nil {
+		return nil, err
+	}
+	if err := lck.requireFile(); err != nil {
+		_ = lck.UnLock()
+		return nil, err
+	}
+	return lck.ReadWriteSeekCloser, nil
+}
+
+// lockContext repeat fn with retry delay until succeed or context Done, and
+// records the wait duration
+func (lck *Lock) lockContext(ctx context.Context, fn func() error, retryDelay time.Duration) error {
+	begin := time.Now()
+	defer lck.watchSlowWait(begin)()
+	ctx, cancel := lck.withBase(ctx)
+	defer cancel()
+	dirSetupSpent := lck.dirSetupSpent
+	release, err := acquireSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if lck.fifoSkipAfter > 0 {
+		var dequeue func()
+		if fn, dequeue, err = lck.fifoWait(fn); err != nil {
+			return wrapPathErr(lck.path, "fifo", err)
+		}
+		defer dequeue()
+	}
+	if lck.contentionCounter {
+		var uncount func()
+		fn, uncount = lck.countedWait(fn)
+		defer uncount()
+	}
+	if lck.sampling != nil {
+		fn = lck.sampledWait(fn)
+	}
+	if err := lck.try(ctx, fn, retryDelay); err != nil {
+		if err == context.Canceled && lck.baseErr() != nil {
+			// ended by the base context
+			return lck.baseErr()
+		}
+		return err
+	}
+	// the lock directory setups done by fn are not part of the wait
+	wait := time.Since(begin) - (lck.dirSetupSpent - dirSetupSpent)
+	if err := lck.recordWaited(wait); err != nil {
+		return err
+	}
+	countWait(wait)
+	if lck.waits != nil {
+		lck.waits.record(wait)
+	}
+	if lck.metrics != nil {
+		lck.metrics.OnAcquire(wait)
+	}
+	return nil
+}
+
+func (lck *Lock) lock(blocking bool) error {
+	return lck.lockAs(Exclusive, blocking)
+}
+
+func (lck *Lock) lockAs(typ LockType, blocking bool) error {
+	return wrapPathErr(lck.path, "lock", lck.setLock(typ, blocking))
+}
+
+// setLock sets the lock of type typ with the lock backend, then updates
+// lck
+func (lck *Lock) setLock(typ LockType, blocking bool) (err error) {
+	if err = lck.checkSetLock(); err != nil {
+		return
+	}
+	if lck.backend == nil {
+		err = lck.setFcntlLock(typ, blocking)
+	} else if blocking {
+		err = ErrNotSupported
+	} else if err = lck.backend.TryAcquire(lck, typ); err == nil {
+		lck.mode = ModeBackend
+	}
+	if err != nil {
+		return
+	}
+	return lck.setHeld(typ, 0, 0)
+}
+
+// checkSetLock verifies a lock can be set: the base context is not done and
+// the identity is valid
+func (lck *Lock) checkSetLock() error {
+	if err := lck.baseErr(); err != nil {
+		return err
+	}
+	return lck.checkIdentity()
+}
+
+// setHeld records the lock of type typ set on length bytes from start, then
+// completes the acquisition
+func (lck *Lock) setHeld(typ LockType, start, length int64) error {
+	lck.heldType = typ
+	lck.heldStart, lck.heldLen = start, length
+	return lck.onAcquired()
+}
+
+// setFcntlLock opens the lock file if needed, then sets the fcntl lock of
+// type typ. The lock file is closed if the lock is not set, unless used by
+// an orphaned call.
+func (lck *Lock) setFcntlLock(typ LockType, blocking bool) (err error) {
 	if lck.ReadWriteSeekCloser == nil {
-		file, err := os.OpenFile(lck.path, os.O_CREATE|os.O_RDWR|os.O_SYNC, 0666)
-		if err != nil {
-			return err
+		if err = lck.open(); err != nil {
+			return
 		}
-		lck.fd = file.Fd()
-		lck.ReadWriteSeekCloser = file
 	}
-	ft := &syscall.Flock_t{
-		Start:  0,
-		Len:    0,
-		Pid:    int32(os.Getpid()),
-		Type:   syscall.F_WRLCK,
-		Whence: io.SeekStart,
+	err = lck.setFcntlRange(wholeFileLock(int16(typ)), blocking)
+	if err != nil && !errors.Is(err, ErrFcntlTimeout) && !lck.callerFile {
+		_ = lck.closeFile()
 	}
+	return
+}
+
+// setFcntlRange sets the fcntl lock ft on the open lock file, and updates the
+// lck mode
+func (lck *Lock) setFcntlRange(ft *unix.Flock_t, blocking bool) (err error) {
 	var cmd int
 	if blocking {
-		cmd = syscall.F_SETLKW
+		cmd = unix.F_SETLKW
 	} else {
-		cmd = syscall.F_SETLK
+		cmd = unix.F_SETLK
 	}
-	if err = syscall.FcntlFlock(lck.fd, cmd, ft); err != nil {
-		_ = lck.Close()
-		lck.ReadWriteSeekCloser = nil
+	if err = lck.fcntl(cmd, ft); err != nil {
+		if errors.Is(err, ErrFcntlTimeout) {
+			// the orphaned call still uses the file descriptor
+			return
+		}
+		if lck.degraded(err) {
+			lck.mode = ModeBestEffortNoop
+			return nil
+		}
+		if isUnsupported(err) {
+			lck.mode = ModeUnsupported
+		}
+		return opError("lock", err)
 	}
+	lck.mode = lck.fcntlMode()
 	return
 }
 
+// onAcquired updates lck after the fcntl lock is set, and runs the post
+// acquire hook. The lock is released if the hook fails.
+func (lck *Lock) onAcquired() error {
+	lck.held = true
+	lck.countAcquired()
+	lck.logf("acquired")
+	if err := lck.recordInode(); err != nil {
+		_ = lck.UnLock()
+		return opError("stat", err)
+	}
+	if lck.truncateOnLock && lck.backend == nil {
+		if err := lck.truncate(); err != nil {
+			_ = lck.UnLock()
+			return opError("truncate", err)
+		}
+	}
+	if lck.templatePending {
+		if err := lck.writeTemplate(); err != nil {
+			_ = lck.UnLock()
+			return opError("write", err)
+		}
+	}
+	if lck.writesMetadata() && lck.backend == nil {
+		lck.meta = lck.newMetadata()
+		if err := lck.writeMetadata(nil); err != nil {
+			_ = lck.UnLock()
+			return opError("write", err)
+		}
+		if lck.lease != nil {
+			lck.startLease()
+		}
+	}
+	if lck.reassert != nil && lck.backend == nil {
+		lck.startReassert()
+	}
+	if lck.orphan != nil {
+		lck.startOrphanWatch()
+	}
+	if lck.postAcquire != nil {
+		if err := lck.postAcquire(lck); err != nil {
+			_ = lck.UnLock()
+			return opError("hook", err)
+		}
+	}
+	return nil
+}
+
+// wholeFileLock returns a Flock_t of type typ covering the whole file
+func wholeFileLock(typ int16) *unix.Flock_t {
+	return &unix.Flock_t{
+		Start:  0,
+		Len:    0,
+		Pid:    int32(os.Getpid()),
+		Type:   typ,
+		Whence: io.SeekStart,
+	}
+}
+
+// open opens the lock file and verifies it against the lck settings
+func (lck *Lock) open() error {
+	return opError("open", lck.openLockFile())
+}
+
+func (lck *Lock) openLockFile() error {
+	flags := lockFileFlags
+	if lck.noFollow {
+		flags |= syscall.O_NOFOLLOW
+	}
+	var (
+		file *os.File
+		err  error
+	)
+	if err := reserveFd(); err != nil {
+		return err
+	}
+	defer func() {
+		if lck.ReadWriteSeekCloser == nil {
+			releaseFd()
+		}
+	}()
+	if lck.atomicCreate {
+		err = lck.createAtomic()
+	} else if lck.createTemplate != nil {
+		// O_EXCL tells if the file is created by this open
+		if file, err = openFile(lck.path, flags|os.O_EXCL, lockFilePerm); err == nil {
+			lck.templatePending = true
+		}
+	}
+	if file == nil && (err == nil || os.IsExist(err)) {
+		file, err = openFile(lck.path, flags, lockFilePerm)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, syscall.ENXIO), errors.Is(err, syscall.EWOULDBLOCK):
+			return &sentinelError{sentinel: ErrOpenWouldBlock, err: err}
+		case errors.Is(err, syscall.ENOSPC):
+			return &sentinelError{sentinel: ErrNoSpace, err: err}
+		case errors.Is(err, syscall.ETXTBSY):
+			return &sentinelError{sentinel: ErrTextBusy, err: err}
+		case errors.Is(err, os.ErrPermission):
+			return lck.inaccessibleErr(err)
+		}
+		return err
+	}
+	if lck.forceCopyUp {
+		if file, err = lck.copyUp(file, flags); err != nil {
+			return err
+		}
+	}
+	fd := file.Fd()
+	if lck.noCloseOnExec {
+		if err := setCloseOnExec(fd, false); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := lck.checkFile(fd); err != nil {
+		_ = file.Close()
+		return err
+	}
+	lck.fd = fd
+	// the created file is grown after the template write
+	if !lck.templatePending {
+		if err := lck.grow(); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := lck.registerFile(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	lck.ReadWriteSeekCloser = file
+	lck.fdCounted = true
+	return nil
+}
+
 func (lck *Lock) try(ctx context.Context, fn func() error, retryDelay time.Duration) error {
+	dirEnsured := false
+	var (
+		lockTableRetries int
+		lockTableDelay   *adaptiveDelay
+		spins            int
+	)
 	for {
+		var delay time.Duration
 		if err := fn(); err == nil {
+			lck.resetRetryDelay()
 			return nil
-		} else if serr, ok := err.(syscall.Errno); !ok || (serr != syscall.EAGAIN) {
+		} else if lck.isContended(err) && spins < lck.initialSpin && ctx.Err() == nil {
+			// will retry after yielding the processor
+			countContention()
+			spins++
+			runtime.Gosched()
+			continue
+		} else if lck.isContended(err) {
+			// will retry after delay
+			countContention()
+			delay = lck.retryDelay(retryDelay)
+			lck.logf("busy, retry in %s", delay)
+		} else if errors.Is(err, syscall.ENOLCK) {
+			// the kernel lock table is full, will retry with backoff
+			if lockTableRetries++; lockTableRetries > lck.enolckRetries {
+				return &sentinelError{sentinel: ErrLockTableFull, err: err}
+			}
+			if lockTableDelay == nil {
+				lockTableDelay = newAdaptiveDelay(retryDelay, 16*retryDelay)
+			}
+			delay = lockTableDelay.next()
+			lck.logf("lock table full, retry in %s", delay)
+		} else if lck.ensureDirOnPermError && !dirEnsured && errors.Is(err, os.ErrPermission) {
+			dirEnsured = true
+			if ensureLockDirPerm(lck.path) != nil {
+				return err
+			}
+			lck.logf("lock directory permissions restored")
+			continue
+		} else {
 			// return immediately
 			return err
 		}
@@ -112,23 +739,122 @@ func (lck *Lock) try(ctx context.Context, fn func() error, retryDelay time.Durat
 		case <-ctx.Done():
 			// context reach end
 			return ctx.Err()
-		case <-time.After(retryDelay):
+		case <-lck.preempt:
+			return ErrPreempted
+		case <-time.After(delay):
 			// will try again fn()
 		}
 	}
 }
 
-func createLockDir(path string) (err error) {
-	dir := filepath.Dir(path)
-	info, err := os.Stat(dir)
+// isContended returns true if err is a lock attempt failure due to a lock
+// held by someone else.
+//
+// Only the fcntl errors are considered, so the lock file access errors
+// remain permission errors. POSIX allows both EAGAIN and EACCES for a lock
+// held by someone else, see WithEACCESAsContention.
+func (lck *Lock) isContended(err error) bool {
+	if errors.Is(err, ErrBusy) {
+		// lock backend contention
+		return true
+	}
+	if e := errors.Unwrap(err); e != nil {
+		// lock path wrapped error
+		err = e
+	}
+	serr, ok := err.(syscall.Errno)
+	if !ok {
+		return false
+	}
+	return serr == syscall.EAGAIN || (serr == syscall.EACCES && !lck.eaccesNotContention)
+}
+
+// WithENOLCKRetries sets to n the number of LockContext retries, with
+// backoff, of a lock attempt failed with ENOLCK: the kernel lock table is
+// full, which is usually transient. A persistent ENOLCK, like on a filesystem
+// not supporting locks, is returned as ErrLockTableFull. The default is 5.
+func WithENOLCKRetries(n int) Option {
+	return func(lck *Lock) {
+		lck.enolckRetries = n
+	}
+}
+
+// WithEACCESAsContention tells if an EACCES fcntl error, after a successful
+// lock file open, means the lock is held by someone else (the default), or is
+// a non retryable permission error, as on some configurations.
+func WithEACCESAsContention(v bool) Option {
+	return func(lck *Lock) {
+		lck.eaccesNotContention = !v
+	}
+}
+
+// WithCreateDir tells if the missing lock directory is created (the
+// default). When false, the lock directory is expected to be managed out of
+// band, and a missing directory makes the lock file open fail with ENOENT.
+func WithCreateDir(v bool) Option {
+	return func(lck *Lock) {
+		lck.noCreateDir = !v
+	}
+}
+
+func (lck *Lock) createLockDir() error {
+	if lck.backend != nil {
+		// no lock file
+		return nil
+	}
+	return wrapPathErr(lck.path, "mkdir", lck.setupDir())
+}
+
+// setupDir ensures the lock directory, and reports the setup duration to the
+// metrics sink
+func (lck *Lock) setupDir() error {
+	if lck.noCreateDir {
+		return nil
+	}
+	begin := time.Now()
+	err := ensureDir(filepath.Dir(lck.path))
+	d := time.Since(begin)
+	lck.dirSetupSpent += d
+	if lck.metrics != nil {
+		lck.metrics.OnDirSetup(d)
+	}
+	lck.logf("lock directory setup in %s", d)
+	return opError("mkdir", err)
+}
+
+func ensureDir(dir string) error {
+	info, err := stat(dir)
 	if err == nil {
 		if info.IsDir() {
-			return
+			return nil
 		}
 		return errors.New("already exists and is not directory: " + dir)
 	}
 	if os.IsNotExist(err) {
-		return os.MkdirAll(dir, lockDirPerm)
+		err = mkdirAll(dir, lockDirPerm)
+		if os.IsExist(err) {
+			// created meanwhile by another process, maybe not as a
+			// directory
+			if info, serr := stat(dir); serr == nil && info.IsDir() {
+				return nil
+			}
+		}
 	}
 	return err
 }
+
+// wrapPathErr returns err as a *LockError of the lock path and operation
+// op, or nil if err is nil. The operation of an error tagged by opError is
+// kept, and a *LockError already having a path is returned as is.
+func wrapPathErr(path, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*LockError); ok {
+		if e.Path == "" {
+			e.Path = path
+		}
+		return e
+	}
+	return &LockError{Path: path, Op: op, Err: err}
+}
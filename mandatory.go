@@ -0,0 +1,27 @@
+package fcntllock
+
+import (
+	"os"
+)
+
+// IsMandatory reports whether mandatory locking is actually in effect on the
+// lock file.
+//
+// fcntl locks are advisory unless the file has the setgid bit set without
+// the group execute bit, and the filesystem is mounted with the mand option.
+// The mount option check is best-effort, and always false on platforms
+// without mandatory locking support.
+func (lck *Lock) IsMandatory() (bool, error) {
+	info, err := os.Stat(lck.path)
+	if err != nil {
+		return false, err
+	}
+	if !hasMandatoryMode(info.Mode()) {
+		return false, nil
+	}
+	return mountAllowsMandatory(lck.path)
+}
+
+func hasMandatoryMode(mode os.FileMode) bool {
+	return mode&os.ModeSetgid != 0 && mode&0010 == 0
+}
@@ -0,0 +1,101 @@
+package fcntllock
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var mountInfoFile = "/proc/self/mountinfo"
+
+// mountAllowsMandatory reports whether the mount holding path has the mand
+// mount option.
+func mountAllowsMandatory(path string) (bool, error) {
+	p, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.Open(mountInfoFile)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+	return parseMountInfoMand(f, p)
+}
+
+// parseMountInfoMand returns the mand option of the longest mount point
+// containing path, from a mountinfo formatted reader.
+//
+// mand is a superblock flag, reported in the super options: the last field,
+// after the "-" separator ending the variable list of optional fields.
+func parseMountInfoMand(r io.Reader, path string) (bool, error) {
+	var (
+		best    string
+		mand    bool
+		scanner = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || sep+3 >= len(fields) {
+			continue
+		}
+		mountPoint := unescapeMountField(fields[4])
+		if !isUnder(path, mountPoint) || len(mountPoint) < len(best) {
+			continue
+		}
+		best = mountPoint
+		mand = false
+		for _, opt := range strings.Split(fields[sep+3], ",") {
+			if opt == "mand" {
+				mand = true
+			}
+		}
+	}
+	return mand, scanner.Err()
+}
+
+func isUnder(path, dir string) bool {
+	if dir == "/" || path == dir {
+		return true
+	}
+	return strings.HasPrefix(path, dir+"/")
+}
+
+// unescapeMountField decodes the octal escapes (\040 for space, ...) used in
+// mountinfo fields.
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) && isOctal(s[i+1:i+4]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '7' {
+			return false
+		}
+	}
+	return true
+}
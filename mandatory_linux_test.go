@@ -0,0 +1,30 @@
+package fcntllock
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMountInfoMand(t *testing.T) {
+	mountInfo := `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,errors=remount-ro
+23 22 8:17 / /srv rw,relatime shared:2 - ext4 /dev/sdb1 rw,mand
+24 23 0:22 / /srv/no\040mand rw,nosuid,nodev shared:3 master:1 - tmpfs tmpfs rw,size=1024k,mode=755
+25 22 8:33 / /data rw,relatime - ext4 /dev/sdc1 rw,data=ordered
+`
+	cases := map[string]bool{
+		"/var/lock/lck":       false,
+		"/srv/lck":            true,
+		"/srv/sub/lck":        true,
+		"/srv/no mand/lck":    false,
+		"/srvfoo/lck":         false,
+		"/srv/no mandfoo/lck": true,
+		"/data/lck":           false,
+	}
+	for path, expected := range cases {
+		mand, err := parseMountInfoMand(strings.NewReader(mountInfo), path)
+		require.NoError(t, err)
+		require.Equalf(t, expected, mand, "path %s", path)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package fcntllock
+
+// mountAllowsMandatory always reports false: mandatory locking is a linux
+// only feature.
+func mountAllowsMandatory(string) (bool, error) {
+	return false, nil
+}
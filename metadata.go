@@ -0,0 +1,251 @@
+package fcntllock
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Metadata is the lock holder information written in the lock file when
+// WithMetadata is used.
+//
+// The metadata is written in the lock file as "key=value" lines, after the
+// WithCreateTemplate template if any.
+type Metadata struct {
+	// PID is the lock holder process id
+	PID int
+
+	// Host is the lock holder hostname
+	Host string
+
+	// Acquired is the lock acquisition time
+	Acquired time.Time
+
+	// Renewed is the last lease renewal time, see WithLease
+	Renewed time.Time
+
+	// Lease is the lease ttl, see WithLease
+	Lease time.Duration
+
+	// Reason is the human readable reason of the acquisition, see
+	// LockReason
+	Reason string
+
+	// Waited is the wait of the acquisition, see WithRecordWait
+	Waited time.Duration
+
+	// Identity is the application level identity of the lock holder, see
+	// WithIdentity
+	Identity string
+}
+
+// WithMetadata writes the holder Metadata in the lock file on each lock
+// acquisition.
+func WithMetadata() Option {
+	return func(lck *Lock) {
+		lck.metadata = true
+	}
+}
+
+// ReadMetadata reads the Metadata of the lock file path. The lock is not
+// required.
+//
+// Like Probe, ReadMetadata uses the descriptor of the lock file open by the
+// process through a Lock, if any, so the locks of the process are kept.
+func ReadMetadata(path string) (*Metadata, error) {
+	var b []byte
+	err := withFileFd(path, func(fd uintptr) (err error) {
+		b, err = readFdContent(fd)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ParseMetadata(b)
+}
+
+// readMetadata reads the Metadata of the lock file, through the lock file
+// descriptor if open, as closing another descriptor of the lock file would
+// release the locks of the process.
+func (lck *Lock) readMetadata() (*Metadata, error) {
+	if lck.ReadWriteSeekCloser == nil {
+		return ReadMetadata(lck.path)
+	}
+	b, err := lck.readContent()
+	if err != nil {
+		return nil, err
+	}
+	return ParseMetadata(b)
+}
+
+// ReadMetadataFrom reads r until EOF, looping on the short reads of slow
+// storages, and parses the read lock file content Metadata.
+func ReadMetadataFrom(r io.Reader) (*Metadata, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMetadata(b)
+}
+
+// ParseMetadata parses the "key=value" lines of b. Lines without "=", like
+// the template comments, and unknown keys are ignored, as well as the
+// trailing zero bytes of a WithMinSize grown file.
+func ParseMetadata(b []byte) (*Metadata, error) {
+	m := &Metadata{}
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimRight(b, "\x00")))
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.Index(line, "=")
+		if i < 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value := line[:i], line[i+1:]
+		var err error
+		switch key {
+		case "pid":
+			m.PID, err = strconv.Atoi(value)
+		case "host":
+			m.Host = value
+		case "acquired":
+			m.Acquired, err = time.Parse(time.RFC3339Nano, value)
+		case "renewed":
+			m.Renewed, err = time.Parse(time.RFC3339Nano, value)
+		case "lease":
+			m.Lease, err = time.ParseDuration(value)
+		case "reason":
+			m.Reason = value
+		case "waited":
+			m.Waited, err = time.ParseDuration(value)
+		case "identity":
+			m.Identity = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata %s: %w", key, err)
+		}
+	}
+	return m, scanner.Err()
+}
+
+// Bytes returns the metadata lock file representation
+func (m *Metadata) Bytes() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "pid=%d\n", m.PID)
+	fmt.Fprintf(&b, "host=%s\n", m.Host)
+	fmt.Fprintf(&b, "acquired=%s\n", m.Acquired.Format(time.RFC3339Nano))
+	if m.Lease > 0 {
+		fmt.Fprintf(&b, "renewed=%s\n", m.Renewed.Format(time.RFC3339Nano))
+		fmt.Fprintf(&b, "lease=%s\n", m.Lease)
+	}
+	if m.Reason != "" {
+		fmt.Fprintf(&b, "reason=%s\n", strings.ReplaceAll(m.Reason, "\n", " "))
+	}
+	if m.Waited > 0 {
+		fmt.Fprintf(&b, "waited=%s\n", m.Waited)
+	}
+	if m.Identity != "" {
+		fmt.Fprintf(&b, "identity=%s\n", m.Identity)
+	}
+	return b.Bytes()
+}
+
+// writesMetadata returns true if the lock file metadata is written on
+// acquisition
+func (lck *Lock) writesMetadata() bool {
+	return lck.metadata || lck.reason != "" || lck.identity != ""
+}
+
+// newMetadata returns the metadata of a lock acquired now by the process
+func (lck *Lock) newMetadata() *Metadata {
+	host, _ := os.Hostname()
+	now := time.Now()
+	m := &Metadata{
+		PID:      os.Getpid(),
+		Host:     host,
+		Acquired: now,
+		Reason:   lck.reason,
+		Identity: lck.identity,
+	}
+	if lck.lease != nil {
+		m.Renewed = now
+		m.Lease = lck.lease.ttl
+	}
+	return m
+}
+
+// writeMetadata applies update to the held lock metadata, if not nil, then
+// replaces the lock file metadata, preserving the template if any.
+//
+// The metadata is written before the truncation of the previous metadata
+// tail, so the readers, like the WithAtomicCreate observers, never see an
+// empty metadata region.
+func (lck *Lock) writeMetadata(update func(m *Metadata)) error {
+	lck.metaMu.Lock()
+	defer lck.metaMu.Unlock()
+	if lck.meta == nil {
+		lck.meta = lck.newMetadata()
+	}
+	if update != nil {
+		update(lck.meta)
+	}
+	offset := lck.metadataOffset()
+	b := lck.meta.Bytes()
+	if _, err := syscall.Pwrite(int(lck.fd), b, offset); err != nil {
+		return err
+	}
+	if err := syscall.Ftruncate(int(lck.fd), offset+int64(len(b))); err != nil {
+		return err
+	}
+	return lck.grow()
+}
+
+// metadataOffset returns the offset of the metadata region: after the
+// template if the lock file begins with it, else 0.
+func (lck *Lock) metadataOffset() int64 {
+	n := len(lck.createTemplate)
+	if n == 0 {
+		return 0
+	}
+	b := make([]byte, n)
+	if err := preadFull(lck.fd, b, 0); err != nil || !bytes.Equal(b, lck.createTemplate) {
+		return 0
+	}
+	return int64(n)
+}
+
+// RepairMetadata validates the lock file metadata, and rewrites it from the
+// held lock state if it is malformed or incomplete, for example after a crash
+// in the middle of a metadata write.
+//
+// The lock must be held by lck, or ErrNotLocked is returned. ErrNoFd is
+// returned when the lock is held without lock file, like with a lock
+// backend.
+func (lck *Lock) RepairMetadata() error {
+	if !lck.HeldByMe() {
+		return ErrNotLocked
+	}
+	if err := lck.requireFile(); err != nil {
+		return err
+	}
+	b, err := lck.readContent()
+	if err != nil {
+		return wrapPathErr(lck.path, "read", err)
+	}
+	if m, err := ParseMetadata(b[lck.metadataOffset():]); err == nil && m.valid() {
+		return nil
+	}
+	lck.logf("repair malformed metadata")
+	return wrapPathErr(lck.path, "write", lck.writeMetadata(nil))
+}
+
+// valid returns true if m has the keys always written by Bytes
+func (m *Metadata) valid() bool {
+	return m.PID > 0 && m.Host != "" && !m.Acquired.IsZero()
+}
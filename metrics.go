@@ -0,0 +1,20 @@
+package fcntllock
+
+import "time"
+
+// Metrics is the interface of the sink receiving the lock timings
+type Metrics interface {
+	// OnDirSetup receives the time spent ensuring the lock directory
+	OnDirSetup(d time.Duration)
+
+	// OnAcquire receives the wait duration of a successful LockContext,
+	// excluding the lock directory setup time
+	OnAcquire(wait time.Duration)
+}
+
+// WithMetrics reports the lock timings to m
+func WithMetrics(m Metrics) Option {
+	return func(lck *Lock) {
+		lck.metrics = m
+	}
+}
@@ -0,0 +1,39 @@
+package fcntllock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedMetrics struct {
+	dirSetups []time.Duration
+	acquires  []time.Duration
+}
+
+func (m *recordedMetrics) OnDirSetup(d time.Duration) { m.dirSetups = append(m.dirSetups, d) }
+
+func (m *recordedMetrics) OnAcquire(d time.Duration) { m.acquires = append(m.acquires, d) }
+
+func TestWithMetricsDirSetup(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+	defer slowStat(50 * time.Millisecond)()
+	m := &recordedMetrics{}
+	lck := New(lockfile, WithMetrics(m)).(*Lock)
+
+	require.NoError(t, lck.LockContext(context.Background(), 10*time.Millisecond))
+	defer func() { _ = lck.UnLock() }()
+
+	// LockContext and its TryLock attempt both ensure the lock directory
+	require.Len(t, m.dirSetups, 2)
+	for _, d := range m.dirSetups {
+		require.GreaterOrEqual(t, int64(d), int64(50*time.Millisecond))
+	}
+	require.Len(t, m.acquires, 1)
+	require.Less(t, int64(m.acquires[0]), int64(50*time.Millisecond),
+		"the acquire time must not include the dir setup time")
+}
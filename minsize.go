@@ -0,0 +1,33 @@
+package fcntllock
+
+import (
+	"syscall"
+)
+
+// WithMinSize grows the lock file to at least n bytes after it is created or
+// opened, for the byte range locking schemes assuming the locked ranges are
+// within the file extent. The file is never shrunk.
+//
+// Only the lock files opened read-write by lck are grown, not the caller
+// files of NewFromRWSC. The template, metadata and truncation writes keep
+// the file grown.
+func WithMinSize(n int64) Option {
+	return func(lck *Lock) {
+		lck.minSize = n
+	}
+}
+
+// grow extends the lock file to the WithMinSize size, if smaller
+func (lck *Lock) grow() error {
+	if lck.minSize <= 0 || lck.callerFile {
+		return nil
+	}
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(lck.fd), &st); err != nil {
+		return err
+	}
+	if st.Size >= lck.minSize {
+		return nil
+	}
+	return syscall.Ftruncate(int(lck.fd), lck.minSize)
+}
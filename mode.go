@@ -0,0 +1,64 @@
+package fcntllock
+
+// Mode is the locking mechanism and semantics in force for a lock
+// acquisition, see EffectiveMode
+type Mode int
+
+const (
+	// ModeNone is the mode of a Lock never acquired
+	ModeNone Mode = iota
+
+	// ModeFcntl is the classic fcntl locks mode, the locks being owned by
+	// the process
+	ModeFcntl
+
+	// ModeOFD is the open file description fcntl locks mode, see WithOFD
+	ModeOFD
+
+	// ModeBackend is a WithBackend custom lock backend mode
+	ModeBackend
+
+	// ModeBestEffortNoop is the WithBestEffort degraded mode: the lock is
+	// reported held, but nothing is locked
+	ModeBestEffortNoop
+
+	// ModeUnsupported is the mode of a lock whose last acquisition failed
+	// because the filesystem doesn't support the fcntl locks, without
+	// WithBestEffort
+	ModeUnsupported
+)
+
+// String implements fmt.Stringer
+func (m Mode) String() string {
+	switch m {
+	case ModeNone:
+		return "none"
+	case ModeFcntl:
+		return "fcntl"
+	case ModeOFD:
+		return "ofd"
+	case ModeBackend:
+		return "backend"
+	case ModeBestEffortNoop:
+		return "best-effort-noop"
+	case ModeUnsupported:
+		return "unsupported"
+	default:
+		return "unknown"
+	}
+}
+
+// EffectiveMode returns the mode in force for the last lock acquisition of
+// lck, successful or failed because locking is not supported. It tells what
+// actually happened with the best effort and backend features.
+func (lck *Lock) EffectiveMode() Mode {
+	return lck.mode
+}
+
+// fcntlMode returns the mode of a successful fcntl lock of lck
+func (lck *Lock) fcntlMode() Mode {
+	if lck.ofd {
+		return ModeOFD
+	}
+	return ModeFcntl
+}
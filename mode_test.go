@@ -0,0 +1,87 @@
+package fcntllock
+
+import (
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+// noopBackend grants every lock attempt
+type noopBackend struct{}
+
+func (noopBackend) TryAcquire(*Lock, LockType) error { return nil }
+
+func (noopBackend) Release(*Lock) error { return nil }
+
+func (noopBackend) Probe(path string) (LockStatus, error) { return LockStatus{Path: path}, nil }
+
+func TestEffectiveMode(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+
+	t.Run("never acquired", func(t *testing.T) {
+		require.Equal(t, ModeNone, New(lockfile).(*Lock).EffectiveMode())
+	})
+
+	t.Run("classic fcntl", func(t *testing.T) {
+		lck := New(lockfile).(*Lock)
+		require.NoError(t, lck.TryLock())
+		require.Equal(t, ModeFcntl, lck.EffectiveMode())
+		require.NoError(t, lck.UnLock())
+	})
+
+	t.Run("ofd", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("OFD locks are only supported on linux")
+		}
+		lck := New(lockfile, WithOFD(true)).(*Lock)
+		require.NoError(t, lck.TryLock())
+		require.Equal(t, ModeOFD, lck.EffectiveMode())
+		require.NoError(t, lck.UnLock())
+	})
+
+	t.Run("custom backend", func(t *testing.T) {
+		lck := New(lockfile, WithBackend(noopBackend{})).(*Lock)
+		require.NoError(t, lck.TryLock())
+		require.Equal(t, ModeBackend, lck.EffectiveMode())
+		require.NoError(t, lck.UnLock())
+	})
+
+	t.Run("best effort noop", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.EOPNOTSUPP, 100, &calls)()
+		lck := New(lockfile, WithBestEffort(true)).(*Lock)
+		require.NoError(t, lck.TryLock())
+		require.Equal(t, ModeBestEffortNoop, lck.EffectiveMode())
+		require.NoError(t, lck.UnLock())
+	})
+
+	t.Run("unsupported without best effort", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.EOPNOTSUPP, 100, &calls)()
+		lck := New(lockfile).(*Lock)
+		require.Error(t, lck.TryLock())
+		require.Equal(t, ModeUnsupported, lck.EffectiveMode())
+	})
+
+	t.Run("supported again after a degraded acquisition", func(t *testing.T) {
+		var calls int
+		restore := failingFcntl(syscall.ENOLCK, 1, &calls)
+		lck := New(lockfile, WithBestEffort(true)).(*Lock)
+		require.NoError(t, lck.TryLock())
+		require.Equal(t, ModeBestEffortNoop, lck.EffectiveMode())
+		require.NoError(t, lck.UnLock())
+		restore()
+		require.NoError(t, lck.TryLock())
+		require.Equal(t, ModeFcntl, lck.EffectiveMode())
+		require.NoError(t, lck.UnLock())
+	})
+
+	t.Run("String", func(t *testing.T) {
+		require.Equal(t, "best-effort-noop", ModeBestEffortNoop.String())
+		require.Equal(t, "unknown", Mode(100).String())
+	})
+}
@@ -0,0 +1,43 @@
+package fcntllock
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxNameLen is the usual filesystems file name length limit
+const maxNameLen = 255
+
+// SanitizeName verifies name is a safe single path component, for lock
+// names coming from untrusted input.
+//
+// Empty names, "." and "..", names longer than 255 bytes, and names with path
+// separators, null bytes or control characters are rejected with
+// ErrInvalidName.
+func SanitizeName(name string) (string, error) {
+	switch {
+	case name == "", name == ".", name == "..":
+		return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+	case len(name) > maxNameLen:
+		return "", fmt.Errorf("%w: longer than %d bytes", ErrInvalidName, maxNameLen)
+	case strings.ContainsAny(name, `/\`):
+		return "", fmt.Errorf("%w: %q contains a path separator", ErrInvalidName, name)
+	}
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f {
+			return "", fmt.Errorf("%w: %q contains a control character", ErrInvalidName, name)
+		}
+	}
+	return name, nil
+}
+
+// NewUnder create a new fcntl lock on the file name of dir. name is verified
+// with SanitizeName.
+func NewUnder(dir, name string, opts ...Option) (Locker, error) {
+	name, err := SanitizeName(name)
+	if err != nil {
+		return nil, err
+	}
+	return New(filepath.Join(dir, name), opts...), nil
+}
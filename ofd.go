@@ -0,0 +1,29 @@
+package fcntllock
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// WithOFD uses the open file description locks (F_OFD_SETLK) instead of the
+// classic process locks. An OFD lock is owned by the open lock file, so it
+// conflicts with the other Locks of the same process, and it is not released
+// by the close of another file descriptor of the lock file.
+//
+// The OFD locks are linux only: the lock attempts fail with ErrNotSupported
+// on the other platforms.
+func WithOFD(v bool) Option {
+	return func(lck *Lock) {
+		lck.ofd = v
+	}
+}
+
+// command returns the fcntl command to run for the classic command cmd, and
+// adapts ft, according to the lck lock kind
+func (lck *Lock) command(cmd int, ft *unix.Flock_t) (int, error) {
+	if !lck.ofd {
+		return cmd, nil
+	}
+	// the OFD lock commands require a zero pid
+	ft.Pid = 0
+	return ofdCommand(cmd)
+}
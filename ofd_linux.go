@@ -0,0 +1,21 @@
+package fcntllock
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// ofdSupported is true where the OFD locks are available
+const ofdSupported = true
+
+// ofdCommand returns the OFD lock command of the classic command cmd
+func ofdCommand(cmd int) (int, error) {
+	switch cmd {
+	case unix.F_SETLK:
+		return unix.F_OFD_SETLK, nil
+	case unix.F_SETLKW:
+		return unix.F_OFD_SETLKW, nil
+	case unix.F_GETLK:
+		return unix.F_OFD_GETLK, nil
+	}
+	return cmd, nil
+}
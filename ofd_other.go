@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package fcntllock
+
+// ofdSupported is true where the OFD locks are available
+const ofdSupported = false
+
+// ofdCommand returns ErrNotSupported: the OFD locks are linux only
+func ofdCommand(int) (int, error) {
+	return 0, ErrNotSupported
+}
@@ -0,0 +1,23 @@
+package fcntllock
+
+import (
+	"context"
+	"time"
+)
+
+// AcquireOnce is LockContext made idempotent by token: a repeated call with
+// the token of the acquisition still held by lck is a no-op success, so a
+// replayed request doesn't acquire the lock again.
+//
+// The token is forgotten on UnLock.
+func (lck *Lock) AcquireOnce(token string, ctx context.Context, retryDelay time.Duration) error {
+	if lck.held && lck.onceToken == token {
+		lck.logf("already acquired with token %s", token)
+		return nil
+	}
+	if err := lck.LockContext(ctx, retryDelay); err != nil {
+		return err
+	}
+	lck.onceToken = token
+	return nil
+}
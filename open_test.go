@@ -0,0 +1,140 @@
+package fcntllock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+// failingOpen makes openFile return errno, until the returned restore func
+// is called. calls counts the openFile calls.
+func failingOpen(errno syscall.Errno, calls *int) (restore func()) {
+	return injectOpen(func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		*calls++
+		return nil, &os.PathError{Op: "open", Path: name, Err: errno}
+	})
+}
+
+func TestOpenENOSPC(t *testing.T) {
+	lockDir, cleanup := testhelper.Tempdir(t)
+	defer cleanup()
+	lockfile := filepath.Join(lockDir, "lck")
+
+	t.Run("TryLock returns ErrNoSpace", func(t *testing.T) {
+		var calls int
+		defer failingOpen(syscall.ENOSPC, &calls)()
+		err := New(lockfile).TryLock()
+		require.ErrorIs(t, err, ErrNoSpace)
+		require.ErrorIs(t, err, syscall.ENOSPC)
+		require.Contains(t, err.Error(), lockfile)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("LockContext fails fast", func(t *testing.T) {
+		var calls int
+		defer failingOpen(syscall.ENOSPC, &calls)()
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		t1 := time.Now()
+		require.ErrorIs(t, New(lockfile).LockContext(ctx, 10*time.Millisecond), ErrNoSpace)
+		require.Less(t, int64(time.Since(t1)), int64(10*time.Millisecond))
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestOpenETXTBSY(t *testing.T) {
+	lockDir, cleanup := testhelper.Tempdir(t)
+	defer cleanup()
+	lockfile := filepath.Join(lockDir, "lck")
+
+	t.Run("TryLock returns ErrTextBusy", func(t *testing.T) {
+		var calls int
+		defer failingOpen(syscall.ETXTBSY, &calls)()
+		err := New(lockfile).TryLock()
+		require.ErrorIs(t, err, ErrTextBusy)
+		require.ErrorIs(t, err, syscall.ETXTBSY)
+		require.Contains(t, err.Error(), lockfile)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("LockContext fails fast", func(t *testing.T) {
+		var calls int
+		defer failingOpen(syscall.ETXTBSY, &calls)()
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		t1 := time.Now()
+		require.ErrorIs(t, New(lockfile).LockContext(ctx, 10*time.Millisecond), ErrTextBusy)
+		require.Less(t, int64(time.Since(t1)), int64(10*time.Millisecond))
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestWithInaccessiblePolicy(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+	missing := filepath.Join(filepath.Dir(lockfile), "no-such-lock-file")
+
+	t.Run("default is the permission error", func(t *testing.T) {
+		var calls int
+		defer failingOpen(syscall.EACCES, &calls)()
+		err := New(lockfile).TryLock()
+		require.ErrorIs(t, err, os.ErrPermission)
+		require.False(t, errors.Is(err, ErrLockFileInaccessible))
+		require.NotContains(t, err.Error(), "not accessible")
+	})
+
+	t.Run("annotate an existing lock file", func(t *testing.T) {
+		var calls int
+		defer failingOpen(syscall.EACCES, &calls)()
+		err := New(lockfile, WithInaccessiblePolicy(InaccessibleAnnotate)).TryLock()
+		require.ErrorIs(t, err, os.ErrPermission)
+		require.Contains(t, err.Error(), fmt.Sprintf("lock file exists but is not accessible, it may be held by a process of uid %d", os.Getuid()))
+	})
+
+	t.Run("error on an existing lock file", func(t *testing.T) {
+		var calls int
+		defer failingOpen(syscall.EACCES, &calls)()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := New(lockfile, WithInaccessiblePolicy(InaccessibleAsError)).LockContext(ctx, time.Millisecond)
+		require.ErrorIs(t, err, ErrLockFileInaccessible)
+		require.ErrorIs(t, err, os.ErrPermission)
+		require.Equal(t, 1, calls, "must fail fast")
+	})
+
+	t.Run("missing lock file is a permission error", func(t *testing.T) {
+		var calls int
+		defer failingOpen(syscall.EACCES, &calls)()
+		for _, policy := range []InaccessiblePolicy{InaccessibleAnnotate, InaccessibleAsError} {
+			err := New(missing, WithInaccessiblePolicy(policy)).TryLock()
+			require.ErrorIs(t, err, os.ErrPermission)
+			require.False(t, errors.Is(err, ErrLockFileInaccessible))
+			require.NotContains(t, err.Error(), "not accessible")
+		}
+	})
+}
+
+func TestProbeAbandonedLock(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+	lck := New(lockfile).(*Lock)
+	require.NoError(t, lck.TryLock())
+
+	// like the finalizer of an abandoned Lock, close the lock file without
+	// unregistering it
+	require.NoError(t, lck.ReadWriteSeekCloser.Close())
+
+	status, err := Probe(lockfile)
+	require.NoError(t, err, "the closed descriptor must not be used")
+	require.False(t, status.Held)
+	_, _, ok := registeredFd(*lck.openID)
+	require.False(t, ok, "the closed descriptor must be unregistered")
+}
@@ -0,0 +1,186 @@
+package fcntllock
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+var (
+	// openFDs is the number of lock files opened by the package
+	openFDs int64
+
+	// maxOpenFDs is the OpenFDs limit, 0 for no limit
+	maxOpenFDs int64
+)
+
+// openFiles is the registry of the lock file descriptors of the process, by
+// file id. Closing any descriptor of a file releases the classic fcntl locks
+// of the process on the file, so the probes use a registered descriptor of
+// the file rather than opening and closing it.
+//
+// A lock file descriptor is registered before its first lock, and
+// unregistered before its close.
+var openFiles struct {
+	sync.Mutex
+	byID map[fileID]*registeredFile
+}
+
+type registeredFile struct {
+	fds []uintptr
+
+	// parked are the files opened by a probe racing with the registration
+	// of a new file at the probed path. They are closed with the last
+	// registered descriptor, as their close would release the locks.
+	parked []*os.File
+}
+
+// OpenFDs returns the number of lock files currently opened by the package.
+// A lock file is kept open from the first lock attempt until Close, or the
+// Handle Close.
+func OpenFDs() int {
+	return int(atomic.LoadInt64(&openFDs))
+}
+
+// SetMaxOpenLocks bounds to n the number of lock files opened by the package,
+// as a guard against file descriptor leaks. A lock attempt needing to open a
+// lock file above the limit fails with ErrTooManyOpenLocks.
+//
+// n <= 0 removes the limit, which is the default.
+func SetMaxOpenLocks(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt64(&maxOpenFDs, int64(n))
+}
+
+// Close closes the lock file, releasing the locks of the process on the file.
+// The next lock attempt opens the lock file again.
+func (lck *Lock) Close() error {
+	return lck.closeFile()
+}
+
+// reserveFd counts a lock file open, or returns ErrTooManyOpenLocks
+func reserveFd() error {
+	for {
+		n := atomic.LoadInt64(&openFDs)
+		if max := atomic.LoadInt64(&maxOpenFDs); max > 0 && n >= max {
+			return ErrTooManyOpenLocks
+		}
+		if atomic.CompareAndSwapInt64(&openFDs, n, n+1) {
+			return nil
+		}
+	}
+}
+
+func releaseFd() {
+	atomic.AddInt64(&openFDs, -1)
+}
+
+// registerFile registers the lock file descriptor lck.fd
+func (lck *Lock) registerFile() error {
+	id, err := fdFileID(lck.fd)
+	if err != nil {
+		return err
+	}
+	openFiles.Lock()
+	defer openFiles.Unlock()
+	if openFiles.byID == nil {
+		openFiles.byID = make(map[fileID]*registeredFile)
+	}
+	f, ok := openFiles.byID[id]
+	if !ok {
+		f = &registeredFile{}
+		openFiles.byID[id] = f
+	}
+	f.fds = append(f.fds, lck.fd)
+	lck.openID = &id
+	return nil
+}
+
+// unregisterFile unregisters the lock file descriptor lck.fd, if registered
+func (lck *Lock) unregisterFile() {
+	if lck.openID == nil {
+		return
+	}
+	id := *lck.openID
+	lck.openID = nil
+	openFiles.Lock()
+	defer openFiles.Unlock()
+	f, ok := openFiles.byID[id]
+	if !ok {
+		return
+	}
+	for i, fd := range f.fds {
+		if fd == lck.fd {
+			f.fds = append(f.fds[:i], f.fds[i+1:]...)
+			break
+		}
+	}
+	if len(f.fds) == 0 {
+		forgetFile(id, f)
+	}
+}
+
+// forgetFile closes the parked files of the registered file f, and removes
+// it from the registry. It must be called with the registry mutex held.
+func forgetFile(id fileID, f *registeredFile) {
+	for _, parked := range f.parked {
+		_ = parked.Close()
+	}
+	delete(openFiles.byID, id)
+}
+
+// registeredFd returns a registered descriptor of the file id. The
+// descriptors no longer open on the file, like those closed by the finalizer
+// of an abandoned Lock, are unregistered. It must be called with the registry
+// mutex held.
+func registeredFd(id fileID) (*registeredFile, uintptr, bool) {
+	f, ok := openFiles.byID[id]
+	if !ok {
+		return nil, 0, false
+	}
+	for len(f.fds) > 0 {
+		if fdID, err := fdFileID(f.fds[0]); err == nil && fdID == id {
+			return f, f.fds[0], true
+		}
+		f.fds = f.fds[1:]
+	}
+	forgetFile(id, f)
+	return nil, 0, false
+}
+
+// withFileFd runs fn with a descriptor of the file path, without releasing
+// the locks of the process on the file: the descriptor of a registered lock
+// file if any, else a descriptor opened for fn and closed after.
+//
+// The locks set on the file by the process through descriptors not
+// registered, like the descriptors opened by the application, are released
+// when withFileFd closes its descriptor.
+func withFileFd(path string, fn func(fd uintptr) error) error {
+	openFiles.Lock()
+	defer openFiles.Unlock()
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err == nil {
+		if _, fd, ok := registeredFd(fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}); ok {
+			return fn(fd)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	id, err := fdFileID(file.Fd())
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	if f, fd, ok := registeredFd(id); ok {
+		// the path was replaced by a registered file since the stat
+		f.parked = append(f.parked, file)
+		return fn(fd)
+	}
+	defer func() { _ = file.Close() }()
+	return fn(file.Fd())
+}
@@ -0,0 +1,91 @@
+package fcntllock
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+type orphanWatch struct {
+	exit     bool
+	exitCode int
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// orphanPollInterval is the parent process poll interval of
+// WithReleaseOnOrphan
+var orphanPollInterval = 500 * time.Millisecond
+
+// WithReleaseOnOrphan makes a background watcher release the held lock when
+// the parent process of the lock holder exits, so an orphaned child doesn't
+// hold the lock indefinitely. The watcher stops on UnLock. See
+// WithExitOnOrphan to also exit the orphaned process.
+//
+// There is no portable parent exit notification, so the watcher polls
+// os.Getppid every 500 milliseconds, and detects the reparenting of the
+// process, to init or to a subreaper. A process whose parent is already init
+// on acquisition, or whose parent is outside its pid namespace, as seen with
+// a parent pid of 0, is never detected orphaned.
+//
+// The lock is released with the lock backend, so HeldByMe is still true
+// until UnLock.
+func WithReleaseOnOrphan(v bool) Option {
+	return func(lck *Lock) {
+		if !v {
+			lck.orphan = nil
+		} else if lck.orphan == nil {
+			lck.orphan = &orphanWatch{}
+		}
+	}
+}
+
+// WithExitOnOrphan is WithReleaseOnOrphan also exiting the process with code
+// once the lock is released.
+func WithExitOnOrphan(code int) Option {
+	return func(lck *Lock) {
+		lck.orphan = &orphanWatch{exit: true, exitCode: code}
+	}
+}
+
+// startOrphanWatch starts the parent process watcher of the acquired lock
+func (lck *Lock) startOrphanWatch() {
+	w := lck.orphan
+	w.stop = make(chan struct{})
+	ppid := os.Getppid()
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(orphanPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				if os.Getppid() == ppid {
+					continue
+				}
+				lck.logf("parent process %d exited, release", ppid)
+				if err := lck.getBackend().Release(lck); err != nil {
+					lck.logf("release orphaned lock: %s", err)
+				}
+				if w.exit {
+					os.Exit(w.exitCode)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// stopOrphanWatch stops the parent process watcher, if running
+func (lck *Lock) stopOrphanWatch() {
+	w := lck.orphan
+	if w == nil || w.stop == nil {
+		return
+	}
+	close(w.stop)
+	w.wg.Wait()
+	w.stop = nil
+}
@@ -0,0 +1,43 @@
+package fcntllock
+
+import (
+	"syscall"
+)
+
+// WithRequireOwner makes the lock fail with ErrWrongOwner when the lock file
+// is not owned by uid, to detect a lock file planted by another user in a
+// shared directory.
+func WithRequireOwner(uid int) Option {
+	return func(lck *Lock) {
+		lck.requireOwner = true
+		lck.ownerUID = uid
+	}
+}
+
+// Owner returns the user and group ids owning the lock file, opening it if
+// needed. The lock is not required.
+func (lck *Lock) Owner() (uid, gid int, err error) {
+	if err = lck.createLockDir(); err != nil {
+		return
+	}
+	if lck.ReadWriteSeekCloser == nil {
+		if err = lck.open(); err != nil {
+			err = wrapPathErr(lck.path, "open", err)
+			return
+		}
+	}
+	var st syscall.Stat_t
+	if err = syscall.Fstat(int(lck.fd), &st); err != nil {
+		err = wrapPathErr(lck.path, "stat", err)
+		return
+	}
+	return int(st.Uid), int(st.Gid), nil
+}
+
+// checkOwner verifies the lock file owner of st against WithRequireOwner
+func (lck *Lock) checkOwner(st *syscall.Stat_t) error {
+	if lck.requireOwner && int(st.Uid) != lck.ownerUID {
+		return ErrWrongOwner
+	}
+	return nil
+}
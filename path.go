@@ -0,0 +1,17 @@
+package fcntllock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+)
+
+// PathForKey returns a deterministic lock file path under dir for the
+// logical resource identified by key.
+//
+// The key is hashed, so any key (url, object id, ...) gives a safe file name
+// of constant length.
+func PathForKey(dir, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,48 @@
+package fcntllock
+
+import (
+	"sync"
+)
+
+// LockPool is a pool of reusable Lock objects, to lower the allocations of
+// the servers locking many ephemeral paths.
+type LockPool struct {
+	pool sync.Pool
+	opts []Option
+}
+
+// NewLockPool returns a LockPool of locks configured by opts
+func NewLockPool(opts ...Option) *LockPool {
+	return &LockPool{opts: opts}
+}
+
+// Get returns a lock of path, reused from the pool if possible, like a lock
+// returned by New.
+func (p *LockPool) Get(path string) *Lock {
+	lck, ok := p.pool.Get().(*Lock)
+	if !ok {
+		lck = &Lock{}
+	}
+	lck.init(path, p.opts)
+	return lck
+}
+
+// Put releases lck if still held, closes its lock file, and returns it to
+// the pool. lck must be a lock returned by Get, and must not be used after
+// Put.
+//
+// A lock failing to release or close its lock file is not returned to the
+// pool, and the error is returned.
+func (p *LockPool) Put(lck *Lock) error {
+	if lck.held {
+		if err := lck.UnLock(); err != nil {
+			_ = lck.closeFile()
+			return err
+		}
+	}
+	if err := lck.closeFile(); err != nil {
+		return wrapPathErr(lck.path, "close", err)
+	}
+	p.pool.Put(lck)
+	return nil
+}
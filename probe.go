@@ -0,0 +1,70 @@
+package fcntllock
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// LockStatus is the status of a lock file, as seen by a probe
+type LockStatus struct {
+	// Path is the lock file path
+	Path string
+
+	// Held is true if a lock is held on the file by another process
+	Held bool
+
+	// Type is the type of the held lock
+	Type LockType
+
+	// PID is the process id of a lock holder
+	PID int
+
+	// Err is the probe error, if any
+	Err error
+}
+
+// Probe returns the lock status of path, using F_GETLK without acquiring
+// the lock.
+//
+// The locks held by the calling process are never reported. When the
+// process has the lock file open through a Lock, the probe uses its
+// descriptor, as closing another descriptor of the file would release the
+// locks of the process on the file.
+func Probe(path string) (LockStatus, error) {
+	status := LockStatus{Path: path}
+	ft := wholeFileLock(unix.F_WRLCK)
+	err := withFileFd(path, func(fd uintptr) error {
+		return fcntlFlock(fd, unix.F_GETLK, ft)
+	})
+	if err != nil {
+		return status, err
+	}
+	if ft.Type != unix.F_UNLCK {
+		status.Held = true
+		status.Type = LockType(ft.Type)
+		status.PID = int(ft.Pid)
+	}
+	return status, nil
+}
+
+// ScanDir probes the regular files of dir, and returns their lock status.
+//
+// A file that can't be probed is not an error: its status Err is set.
+func ScanDir(dir string) ([]LockStatus, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	l := make([]LockStatus, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		status, err := Probe(filepath.Join(dir, entry.Name()))
+		status.Err = err
+		l = append(l, status)
+	}
+	return l, nil
+}
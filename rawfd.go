@@ -0,0 +1,47 @@
+package fcntllock
+
+import (
+	"os"
+)
+
+// RawFd returns the lock file descriptor, for example to pass the lock to
+// another process over a unix socket with SCM_RIGHTS. ErrNotLocked is
+// returned if the lock is not held by lck.
+func (lck *Lock) RawFd() (uintptr, error) {
+	if !lck.held {
+		return 0, ErrNotLocked
+	}
+	return lck.fd, nil
+}
+
+// WithHeldType sets the type of the lock held through the FromRawFd
+// descriptor, Exclusive by default. The other locks ignore it: their held
+// type is the type of their acquisition.
+func WithHeldType(typ LockType) Option {
+	return func(lck *Lock) {
+		lck.heldType = typ
+	}
+}
+
+// FromRawFd reconstructs a lock of path from a lock file descriptor, for
+// example received from another process with SCM_RIGHTS or inherited, and
+// configured by opts. held tells if the lock is held through fd, Exclusive
+// unless WithHeldType is set.
+//
+// The classic fcntl locks are owned by the process, not the file
+// descriptor: a lock handed off to another process is not held by the
+// receiver process. Only the OFD locks, owned by the open file description,
+// are preserved by such a handoff, and the receiver must use WithOFD.
+func FromRawFd(fd uintptr, path string, held bool, opts ...Option) Locker {
+	lck := &Lock{}
+	lck.init(path, append([]Option{WithHeldType(Exclusive)}, opts...))
+	lck.ReadWriteSeekCloser = os.NewFile(fd, lck.path)
+	lck.fd = fd
+	if held {
+		lck.held = true
+		lck.mode = lck.fcntlMode()
+	}
+	// a bad fd is reported by the lock methods
+	_ = lck.registerFile()
+	return lck
+}
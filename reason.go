@@ -0,0 +1,22 @@
+package fcntllock
+
+import (
+	"context"
+	"time"
+)
+
+// LockReason is LockContext recording the human readable reason of the
+// acquisition, like "running backup", in the lock file Metadata, so the
+// operators reading the lock file understand why it is held. The metadata is
+// written even without WithMetadata.
+//
+// The newlines of reason are replaced by spaces. The reason is forgotten on
+// UnLock.
+func (lck *Lock) LockReason(ctx context.Context, retryDelay time.Duration, reason string) error {
+	lck.reason = reason
+	if err := lck.LockContext(ctx, retryDelay); err != nil {
+		lck.reason = ""
+		return err
+	}
+	return nil
+}
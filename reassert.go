@@ -0,0 +1,67 @@
+package fcntllock
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+type reassert struct {
+	interval time.Duration
+	onLost   func(error)
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// WithReassert makes a background verifier re-issue the held lock every
+// interval, while the lock is held, to detect a lock silently dropped, for
+// example by a NFS server reboot. onLost is called with the error of each
+// failed re-assertion. The verifier stops on UnLock, or when the WithContext
+// base context is done.
+//
+// A process always succeeds re-issuing its own classic fcntl lock, so
+// WithReassert is meant to be used with WithOFD.
+func WithReassert(interval time.Duration, onLost func(error)) Option {
+	return func(lck *Lock) {
+		lck.reassert = &reassert{interval: interval, onLost: onLost}
+	}
+}
+
+// startReassert starts the verifier of the acquired lock
+func (lck *Lock) startReassert() {
+	r := lck.reassert
+	r.stop = make(chan struct{})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-lck.baseDone():
+				return
+			case <-ticker.C:
+				if err := lck.fcntl(unix.F_SETLK, wholeFileLock(int16(lck.heldType))); err != nil {
+					lck.logf("reassert: %s", err)
+					if r.onLost != nil {
+						r.onLost(wrapPathErr(lck.path, "lock", err))
+					}
+				}
+			}
+		}
+	}()
+}
+
+// stopReassert stops the verifier, if running
+func (lck *Lock) stopReassert() {
+	r := lck.reassert
+	if r == nil || r.stop == nil {
+		return
+	}
+	close(r.stop)
+	r.wg.Wait()
+	r.stop = nil
+}
@@ -0,0 +1,62 @@
+package fcntllock
+
+import (
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// droppingFcntl makes the non unlock F_SETLK calls fail with errno after the
+// first granted ones, simulating a lock silently dropped
+func droppingFcntl(errno syscall.Errno, granted int32, calls *int32) (restore func()) {
+	return injectFcntl(func(fd uintptr, cmd int, lk *unix.Flock_t) error {
+		if cmd == unix.F_SETLK && lk.Type != unix.F_UNLCK {
+			if atomic.AddInt32(calls, 1) > granted {
+				return errno
+			}
+		}
+		return unix.FcntlFlock(fd, cmd, lk)
+	})
+}
+
+func TestWithReassert(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+
+	t.Run("callback fires when the re-assertion fails", func(t *testing.T) {
+		var calls int32
+		defer droppingFcntl(syscall.EAGAIN, 1, &calls)()
+		lost := make(chan error, 10)
+		lck := New(lockfile, WithReassert(10*time.Millisecond, func(err error) {
+			lost <- err
+		})).(*Lock)
+		require.NoError(t, lck.TryLock())
+		select {
+		case err := <-lost:
+			require.ErrorIs(t, err, syscall.EAGAIN)
+		case <-time.After(time.Second):
+			t.Fatal("onLost not called")
+		}
+		require.NoError(t, lck.UnLock())
+	})
+
+	t.Run("verifier stops on UnLock", func(t *testing.T) {
+		var calls int32
+		defer droppingFcntl(syscall.EAGAIN, 1000, &calls)()
+		lck := New(lockfile, WithReassert(5*time.Millisecond, func(err error) {
+			t.Errorf("unexpected lock lost: %s", err)
+		})).(*Lock)
+		require.NoError(t, lck.TryLock())
+		time.Sleep(30 * time.Millisecond)
+		require.NoError(t, lck.UnLock())
+		reasserted := atomic.LoadInt32(&calls)
+		require.Greater(t, reasserted, int32(1), "the lock must be re-issued")
+		time.Sleep(30 * time.Millisecond)
+		require.Equal(t, reasserted, atomic.LoadInt32(&calls), "no re-assertion after UnLock")
+	})
+}
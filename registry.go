@@ -0,0 +1,170 @@
+package fcntllock
+
+import (
+	"container/heap"
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type (
+	// localLock serializes the lock holders of the process for a lock path,
+	// as fcntl never reports a conflict between locks of the same process
+	localLock struct {
+		held    bool
+		waiters waiterQueue
+	}
+
+	localWaiter struct {
+		priority int
+		seq      uint64
+		index    int
+		ready    chan struct{}
+	}
+
+	// waiterQueue is a heap of waiters, the highest priority first, then
+	// the first arrived
+	waiterQueue []*localWaiter
+)
+
+// registry is the in-process registry of the local locks, by lock path
+var registry struct {
+	sync.Mutex
+	locks map[string]*localLock
+	seq   uint64
+}
+
+// AcquireWithPriority is LockContext preceded by a wait for the in-process
+// lock of the path: when it is released, the waiting goroutine with the
+// highest priority gets it first, then the fcntl lock is attempted. Equal
+// priorities are served in arrival order.
+//
+// The ordering between processes remains defined by the kernel.
+func (lck *Lock) AcquireWithPriority(ctx context.Context, retryDelay time.Duration, priority int) error {
+	key := registryKey(lck.path)
+	if err := acquireLocal(ctx, key, priority); err != nil {
+		return err
+	}
+	if err := lck.LockContext(ctx, retryDelay); err != nil {
+		releaseLocal(key)
+		return err
+	}
+	lck.localKey = key
+	return nil
+}
+
+// releaseLocalLock releases the in-process lock acquired by
+// AcquireWithPriority, if any
+func (lck *Lock) releaseLocalLock() {
+	if lck.localKey == "" {
+		return
+	}
+	releaseLocal(lck.localKey)
+	lck.localKey = ""
+}
+
+// registryKey returns the normalized path, so the paths resolving to the
+// same file through symlinks share the same in-process lock.
+//
+// The symlinks are resolved best effort: if the lock file doesn't exist yet,
+// only its directory is resolved, and if the directory can't be resolved
+// either, the absolute path is used.
+func registryKey(path string) string {
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		return p
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		return filepath.Join(dir, filepath.Base(path))
+	}
+	if p, err := filepath.Abs(path); err == nil {
+		return p
+	}
+	return filepath.Clean(path)
+}
+
+// acquireLocal waits for the in-process lock of key, or ctx Done
+func acquireLocal(ctx context.Context, key string, priority int) error {
+	registry.Lock()
+	if registry.locks == nil {
+		registry.locks = make(map[string]*localLock)
+	}
+	l, ok := registry.locks[key]
+	if !ok {
+		l = &localLock{}
+		registry.locks[key] = l
+	}
+	if !l.held {
+		l.held = true
+		registry.Unlock()
+		return nil
+	}
+	registry.seq++
+	w := &localWaiter{priority: priority, seq: registry.seq, ready: make(chan struct{})}
+	heap.Push(&l.waiters, w)
+	registry.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		registry.Lock()
+		select {
+		case <-w.ready:
+			// granted meanwhile, pass it on
+			registry.Unlock()
+			releaseLocal(key)
+		default:
+			heap.Remove(&l.waiters, w.index)
+			registry.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// releaseLocal hands the in-process lock of key to the next waiter, or
+// releases it
+func releaseLocal(key string) {
+	registry.Lock()
+	defer registry.Unlock()
+	l, ok := registry.locks[key]
+	if !ok {
+		return
+	}
+	if l.waiters.Len() > 0 {
+		w := heap.Pop(&l.waiters).(*localWaiter)
+		close(w.ready)
+		return
+	}
+	delete(registry.locks, key)
+}
+
+func (q waiterQueue) Len() int { return len(q) }
+
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waiterQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waiterQueue) Push(x interface{}) {
+	w := x.(*localWaiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waiterQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return w
+}
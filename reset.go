@@ -0,0 +1,31 @@
+package fcntllock
+
+import (
+	"io"
+	"syscall"
+)
+
+// Reset truncates the lock file to zero length, and rewrites fresh
+// metadata if WithMetadata, WithIdentity or LockReason is used.
+//
+// The lock must be held by lck, or ErrNotLocked is returned. ErrNoFd is
+// returned when the lock is held without lock file, like with a lock
+// backend.
+func (lck *Lock) Reset() error {
+	if !lck.HeldByMe() {
+		return ErrNotLocked
+	}
+	if err := lck.requireFile(); err != nil {
+		return err
+	}
+	if err := syscall.Ftruncate(int(lck.fd), 0); err != nil {
+		return err
+	}
+	if lck.writesMetadata() {
+		if err := lck.writeMetadata(nil); err != nil {
+			return err
+		}
+	}
+	_, err := lck.Seek(0, io.SeekStart)
+	return err
+}
@@ -0,0 +1,63 @@
+package fcntllock
+
+import (
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Revalidate verifies the held lock is still effective, for long running
+// holders: the lock file descriptor is still valid and writable, no other
+// process holds a lock conflicting with the held lock, and the lock path
+// still points to the locked file.
+//
+// It returns ErrNotLocked if the lock is not held by lck, ErrNoFd if it is
+// held without lock file, like with a lock backend, and an error matching
+// ErrLockLost if the environment changed under the holder.
+func (lck *Lock) Revalidate() error {
+	if !lck.held {
+		return ErrNotLocked
+	}
+	if err := lck.requireFile(); err != nil {
+		return err
+	}
+	return wrapPathErr(lck.path, "revalidate", lck.revalidate())
+}
+
+func (lck *Lock) revalidate() error {
+	lost := func(err error) error {
+		return &sentinelError{sentinel: ErrLockLost, err: err}
+	}
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, lck.fd, syscall.F_GETFL, 0)
+	if errno != 0 {
+		return lost(errno)
+	}
+	if flags&syscall.O_ACCMODE != syscall.O_RDWR {
+		return lost(fmt.Errorf("lock file descriptor is not writable"))
+	}
+
+	// the locks of the calling process are never reported, so any lock
+	// conflicting with the held lock type and range is a lock of another
+	// process
+	ft := wholeFileLock(int16(lck.heldType))
+	ft.Start, ft.Len = lck.heldStart, lck.heldLen
+	if err := lck.fcntl(unix.F_GETLK, ft); err != nil {
+		return lost(err)
+	}
+	if ft.Type != unix.F_UNLCK {
+		return lost(fmt.Errorf("%s lock held by pid %d", LockType(ft.Type), ft.Pid))
+	}
+
+	var fdSt, pathSt syscall.Stat_t
+	if err := syscall.Fstat(int(lck.fd), &fdSt); err != nil {
+		return lost(err)
+	}
+	if err := syscall.Stat(lck.path, &pathSt); err != nil {
+		return lost(err)
+	}
+	if fdSt.Dev != pathSt.Dev || fdSt.Ino != pathSt.Ino {
+		return lost(fmt.Errorf("lock path points to another file"))
+	}
+	return nil
+}
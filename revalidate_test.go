@@ -0,0 +1,43 @@
+package fcntllock
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevalidateInvalidatedFd(t *testing.T) {
+	t.Run("bad file descriptor", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		lck := New(lockfile).(*Lock)
+		require.NoError(t, lck.TryLock())
+		defer func() { _ = lck.UnLock() }()
+		fd := lck.fd
+		defer func() { lck.fd = fd }()
+
+		lck.fd = 1 << 20
+		err := lck.Revalidate()
+		require.ErrorIs(t, err, ErrLockLost)
+		require.ErrorIs(t, err, syscall.EBADF)
+	})
+
+	t.Run("read only file descriptor", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		lck := New(lockfile).(*Lock)
+		require.NoError(t, lck.TryLock())
+		defer func() { _ = lck.UnLock() }()
+		fd := lck.fd
+		defer func() { lck.fd = fd }()
+
+		f, err := os.Open(lockfile)
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+		lck.fd = f.Fd()
+		require.ErrorIs(t, lck.Revalidate(), ErrLockLost)
+	})
+}
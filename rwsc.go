@@ -0,0 +1,33 @@
+package fcntllock
+
+import (
+	"syscall"
+)
+
+// NewFromRWSC creates a fcntl lock on the caller provided lock file f, for
+// example a wrapper of *os.File adding instrumentation. f must implement
+// FdReadWriteSeekCloser with a valid file descriptor, else an error matching
+// ErrNoFd is returned.
+//
+// f is not closed on lock failure, but it is closed by the Handle Close.
+func NewFromRWSC(path string, f ReadWriteSeekCloser, opts ...Option) (Locker, error) {
+	ff, ok := f.(FdReadWriteSeekCloser)
+	if !ok {
+		return nil, ErrNoFd
+	}
+	fd := ff.Fd()
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFD, 0); errno != 0 {
+		return nil, &sentinelError{sentinel: ErrNoFd, err: errno}
+	}
+	lck := New(path, opts...).(*Lock)
+	if err := lck.checkFile(fd); err != nil {
+		return nil, wrapPathErr(path, "open", err)
+	}
+	lck.fd = fd
+	if err := lck.registerFile(); err != nil {
+		return nil, wrapPathErr(path, "open", err)
+	}
+	lck.ReadWriteSeekCloser = f
+	lck.callerFile = true
+	return lck, nil
+}
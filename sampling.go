@@ -0,0 +1,50 @@
+package fcntllock
+
+import (
+	"math/rand"
+	"runtime"
+)
+
+// sampledStackSize is the maximum size of a WithContentionSampling stack
+// trace
+const sampledStackSize = 16 << 10
+
+type contentionSampling struct {
+	rate     float64
+	onSample func(stack []byte)
+}
+
+// WithContentionSampling samples the LockContext acquisitions at rate,
+// between 0 and 1, and calls onSample with the stack trace of the acquiring
+// goroutine when a sampled acquisition hits its first contention, to find
+// the most contending code paths at a low cost.
+//
+// onSample is called on the acquiring goroutine, before the retry delay.
+func WithContentionSampling(rate float64, onSample func(stack []byte)) Option {
+	return func(lck *Lock) {
+		if rate <= 0 || onSample == nil {
+			lck.sampling = nil
+			return
+		}
+		lck.sampling = &contentionSampling{rate: rate, onSample: onSample}
+	}
+}
+
+// sampledWait returns fn capturing the stack trace on its first contention,
+// if the acquisition is sampled
+func (lck *Lock) sampledWait(fn func() error) func() error {
+	s := lck.sampling
+	if s.rate < 1 && rand.Float64() >= s.rate {
+		return fn
+	}
+	var captured bool
+	return func() error {
+		err := fn()
+		if !captured && lck.isContended(err) {
+			captured = true
+			buf := make([]byte, sampledStackSize)
+			s.onSample(buf[:runtime.Stack(buf, false)])
+		}
+		return err
+	}
+}
@@ -0,0 +1,38 @@
+package fcntllock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LockScoped acquires the lock with LockContext, and binds it to the
+// returned scoped context: the lock is released when ctx is Done or when
+// release is called, whichever comes first, and the scoped context is
+// cancelled by the release. The lock is released only once.
+//
+// Pass the scoped context to the lock protected work, like an errgroup
+// worker, so it stops when the lock is released.
+func (lck *Lock) LockScoped(ctx context.Context, retryDelay time.Duration) (scoped context.Context, release func(), err error) {
+	if err = lck.LockContext(ctx, retryDelay); err != nil {
+		return nil, nil, err
+	}
+	scoped, cancel := context.WithCancel(ctx)
+	var once sync.Once
+	done := make(chan struct{})
+	release = func() {
+		once.Do(func() {
+			cancel()
+			if err := lck.UnLock(); err != nil {
+				lck.logf("scoped release: %s", err)
+			}
+			close(done)
+		})
+		<-done
+	}
+	go func() {
+		<-scoped.Done()
+		release()
+	}()
+	return scoped, release, nil
+}
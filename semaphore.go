@@ -0,0 +1,120 @@
+package fcntllock
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Semaphore is a cross-process counting semaphore of n slots, using the n
+// first bytes of a single lock file as independent fcntl byte-range locks.
+type Semaphore struct {
+	lck        *Lock
+	retryDelay time.Duration
+
+	mu sync.Mutex
+	// held tracks the slots acquired by this process, as fcntl never
+	// reports a conflict between locks of the same process
+	held []bool
+}
+
+// NewSemaphore creates a Semaphore of n slots on the lock file path, polling
+// for a free slot every retryDelay.
+func NewSemaphore(path string, n int, retryDelay time.Duration, opts ...Option) *Semaphore {
+	return &Semaphore{
+		lck:        New(path, opts...).(*Lock),
+		retryDelay: retryDelay,
+		held:       make([]bool, n),
+	}
+}
+
+// Acquire locks a free slot, waiting until one is released or ctx is Done.
+// The acquired slot must be passed to Release.
+func (s *Semaphore) Acquire(ctx context.Context) (slot int, err error) {
+	if err := s.lck.createLockDir(); err != nil {
+		return -1, err
+	}
+	for {
+		if slot, err = s.tryAcquire(); err != nil {
+			return -1, wrapPathErr(s.lck.path, "lock", err)
+		} else if slot >= 0 {
+			return slot, nil
+		}
+		s.lck.logf("no free slot, retry in %s", s.retryDelay)
+		select {
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		case <-time.After(s.retryDelay):
+		}
+	}
+}
+
+// tryAcquire probes the slots not held by this process, and returns the
+// first one locked, or -1 if they are all busy.
+func (s *Semaphore) tryAcquire() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lck.ReadWriteSeekCloser == nil {
+		if err := s.lck.open(); err != nil {
+			return -1, err
+		}
+	}
+	for slot, held := range s.held {
+		if held {
+			continue
+		}
+		err := fcntlFlock(s.lck.fd, unix.F_SETLK, slotLock(slot, unix.F_WRLCK))
+		switch {
+		case err == nil:
+			s.held[slot] = true
+			return slot, nil
+		case s.lck.isContended(err):
+			continue
+		default:
+			return -1, err
+		}
+	}
+	return -1, nil
+}
+
+// Release unlocks slot. It returns ErrNotLocked if slot is not held by s.
+func (s *Semaphore) Release(slot int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slot < 0 || slot >= len(s.held) || !s.held[slot] {
+		return ErrNotLocked
+	}
+	if err := fcntlFlock(s.lck.fd, unix.F_SETLK, slotLock(slot, unix.F_UNLCK)); err != nil {
+		return wrapPathErr(s.lck.path, "unlock", err)
+	}
+	s.held[slot] = false
+	return nil
+}
+
+// Close closes the lock file, releasing all the slots held by s.
+func (s *Semaphore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for slot := range s.held {
+		s.held[slot] = false
+	}
+	return s.lck.closeFile()
+}
+
+// slotLock returns a Flock_t of type typ covering the byte at offset slot
+func slotLock(slot int, typ int16) *unix.Flock_t {
+	return rangeLock(int64(slot), 1, typ)
+}
+
+// rangeLock returns a Flock_t of type typ covering length bytes at start
+func rangeLock(start, length int64, typ int16) *unix.Flock_t {
+	return &unix.Flock_t{
+		Start:  start,
+		Len:    length,
+		Type:   typ,
+		Whence: io.SeekStart,
+	}
+}
@@ -0,0 +1,56 @@
+package fcntllock
+
+import (
+	"io/ioutil"
+	"syscall"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+// injectShortPread makes pread return at most n bytes per call, until the
+// returned restore func is called
+func injectShortPread(n int) (restore func()) {
+	pread = func(fd int, b []byte, offset int64) (int, error) {
+		if len(b) > n {
+			b = b[:n]
+		}
+		return syscall.Pread(fd, b, offset)
+	}
+	return func() { pread = syscall.Pread }
+}
+
+func TestShortPread(t *testing.T) {
+	template := []byte("# lock file, do not edit\n")
+
+	t.Run("metadata rewrite preserves the template", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, template, 0600))
+		defer injectShortPread(3)()
+		l := New(lockfile, WithCreateTemplate(template), WithMetadata()).(*Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		require.Equal(t, int64(len(template)), l.metadataOffset())
+
+		require.NoError(t, l.writeMetadata(nil))
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, string(template), string(b[:len(template)]))
+	})
+
+	t.Run("content is assembled from the short reads", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, []byte("version=1\nlonger payload"), 0600))
+		defer injectShortPread(2)()
+		l := New(lockfile).(*Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		swapped, err := l.CompareAndSwap([]byte("version=1\nlonger payload"), []byte("version=2\n"))
+		require.NoError(t, err)
+		require.True(t, swapped)
+	})
+
+}
@@ -0,0 +1,52 @@
+package fcntllock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SingleInstance acquires the single instance lock of the program name, so
+// only one instance of the program runs for the user. The returned Locker
+// holds the lock, to release on exit, or implicitly released by the process
+// exit.
+//
+// The lock file is "<name>.lock" in $XDG_RUNTIME_DIR, or "<name>.<uid>.lock"
+// in the temporary directory when $XDG_RUNTIME_DIR is not set. name must be
+// a safe path component, see SanitizeName. In the temporary directory, shared
+// by the users, a lock file not owned by the user fails with ErrWrongOwner,
+// and a symlink lock path fails with an error matching syscall.ELOOP.
+//
+// An *AlreadyRunningError, with the pid of the running instance, is returned
+// if another instance holds the lock.
+func SingleInstance(name string) (Locker, error) {
+	name, err := SanitizeName(name)
+	if err != nil {
+		return nil, err
+	}
+	path, shared := singleInstancePath(name)
+	opts := []Option{WithMetadata()}
+	if shared {
+		opts = append(opts, WithRequireOwner(os.Getuid()), WithNoFollow(true))
+	}
+	lck := New(path, opts...).(*Lock)
+	err = lck.TryLock()
+	if err == nil {
+		return lck, nil
+	}
+	_ = lck.Close()
+	if !lck.isContended(err) {
+		return nil, err
+	}
+	pid, _, _ := holderPID(path)
+	return nil, &AlreadyRunningError{Path: path, PID: pid}
+}
+
+// singleInstancePath returns the single instance lock path of name, and
+// reports if its directory is shared by the users
+func singleInstancePath(name string) (string, bool) {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, name+".lock"), false
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s.%d.lock", name, os.Getuid())), true
+}
@@ -0,0 +1,39 @@
+package fcntllock
+
+import (
+	"time"
+)
+
+type slowWait struct {
+	threshold time.Duration
+	onSlow    func(waited time.Duration)
+}
+
+// WithSlowWaitThreshold makes LockContext call onSlow once, with the elapsed
+// wait, when the acquisition is still waiting after d, to alert on stuck
+// locks before the context timeout. The acquisition continues.
+//
+// onSlow runs on its own goroutine, so a slow callback doesn't delay the
+// retries, and may still be running when LockContext returns.
+func WithSlowWaitThreshold(d time.Duration, onSlow func(waited time.Duration)) Option {
+	return func(lck *Lock) {
+		if d <= 0 || onSlow == nil {
+			lck.slowWait = nil
+			return
+		}
+		lck.slowWait = &slowWait{threshold: d, onSlow: onSlow}
+	}
+}
+
+// watchSlowWait arms the slow wait alert of an acquisition begun at begin.
+// The returned stop func disarms it.
+func (lck *Lock) watchSlowWait(begin time.Time) (stop func()) {
+	s := lck.slowWait
+	if s == nil {
+		return func() {}
+	}
+	timer := time.AfterFunc(s.threshold-time.Since(begin), func() {
+		s.onSlow(time.Since(begin))
+	})
+	return func() { timer.Stop() }
+}
@@ -0,0 +1,72 @@
+package fcntllock
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithInitialSpin(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("short contention is absorbed by the spin", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.EAGAIN, 3, &calls)()
+		l := New(lockfile, WithInitialSpin(5))
+		begin := time.Now()
+		require.NoError(t, l.LockContext(ctx, time.Second))
+		require.Less(t, int64(time.Since(begin)), int64(500*time.Millisecond))
+		require.Equal(t, 4, calls)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("longer contention falls back to the retry delay", func(t *testing.T) {
+		var calls int
+		defer failingFcntl(syscall.EAGAIN, 3, &calls)()
+		l := New(lockfile, WithInitialSpin(2))
+		begin := time.Now()
+		require.NoError(t, l.LockContext(ctx, 50*time.Millisecond))
+		require.GreaterOrEqual(t, int64(time.Since(begin)), int64(50*time.Millisecond))
+		require.Equal(t, 4, calls)
+		require.NoError(t, l.UnLock())
+	})
+}
+
+// BenchmarkLockContextInitialSpin compares the acquisition latency under a
+// light contention, simulated by 2 contended attempts per acquisition.
+func BenchmarkLockContextInitialSpin(b *testing.B) {
+	f, err := ioutil.TempFile("", "fcntllock-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	lockfile := f.Name()
+	_ = f.Close()
+	defer os.Remove(lockfile)
+	for _, spin := range []int{0, 4} {
+		b.Run(fmt.Sprintf("spin=%d", spin), func(b *testing.B) {
+			l := New(lockfile, WithInitialSpin(spin))
+			ctx := context.Background()
+			for i := 0; i < b.N; i++ {
+				var calls int
+				restore := failingFcntl(syscall.EAGAIN, 2, &calls)
+				if err := l.LockContext(ctx, time.Millisecond); err != nil {
+					b.Fatal(err)
+				}
+				restore()
+				if err := l.UnLock(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
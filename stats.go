@@ -0,0 +1,85 @@
+package fcntllock
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Counters are the aggregate lock counters of the process
+type Counters struct {
+	// Acquisitions is the number of successful lock acquisitions
+	Acquisitions int64
+
+	// Contentions is the number of lock attempts failed because the lock
+	// was held by someone else
+	Contentions int64
+
+	// Held is the number of locks currently held
+	Held int64
+
+	// WaitTime is the total wait duration of the successful LockContext
+	WaitTime time.Duration
+}
+
+// counters are the process counters, updated with atomic operations
+var counters struct {
+	acquisitions int64
+	contentions  int64
+	held         int64
+	waitTime     int64
+}
+
+// Stats returns the aggregate lock counters of the process
+func Stats() Counters {
+	return Counters{
+		Acquisitions: atomic.LoadInt64(&counters.acquisitions),
+		Contentions:  atomic.LoadInt64(&counters.contentions),
+		Held:         atomic.LoadInt64(&counters.held),
+		WaitTime:     time.Duration(atomic.LoadInt64(&counters.waitTime)),
+	}
+}
+
+// WriteMetrics writes the aggregate lock counters of the process to w, in
+// the Prometheus text exposition format.
+func WriteMetrics(w io.Writer) error {
+	s := Stats()
+	_, err := fmt.Fprintf(w, `# HELP fcntllock_acquisitions_total Number of successful lock acquisitions.
+# TYPE fcntllock_acquisitions_total counter
+fcntllock_acquisitions_total %d
+# HELP fcntllock_contentions_total Number of lock attempts failed on a lock held by someone else.
+# TYPE fcntllock_contentions_total counter
+fcntllock_contentions_total %d
+# HELP fcntllock_held Number of locks currently held.
+# TYPE fcntllock_held gauge
+fcntllock_held %d
+# HELP fcntllock_wait_seconds_total Total wait duration of the successful lock acquisitions.
+# TYPE fcntllock_wait_seconds_total counter
+fcntllock_wait_seconds_total %g
+`, s.Acquisitions, s.Contentions, s.Held, s.WaitTime.Seconds())
+	return err
+}
+
+func (lck *Lock) countAcquired() {
+	atomic.AddInt64(&counters.acquisitions, 1)
+	if !lck.statsHeld {
+		lck.statsHeld = true
+		atomic.AddInt64(&counters.held, 1)
+	}
+}
+
+func (lck *Lock) countReleased() {
+	if lck.statsHeld {
+		lck.statsHeld = false
+		atomic.AddInt64(&counters.held, -1)
+	}
+}
+
+func countContention() {
+	atomic.AddInt64(&counters.contentions, 1)
+}
+
+func countWait(d time.Duration) {
+	atomic.AddInt64(&counters.waitTime, int64(d))
+}
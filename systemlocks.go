@@ -0,0 +1,67 @@
+package fcntllock
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// SystemLock is a lock held or awaited on the system, as listed by the
+// kernel, see SystemLocks.
+type SystemLock struct {
+	// ID is the kernel lock list position
+	ID int
+
+	// Blocked is true for a lock request waiting for the lock ID
+	Blocked bool
+
+	// Class is the lock class: "POSIX", "OFDLCK", "FLOCK", "LEASE", ...
+	Class string
+
+	// Mandatory is true for a mandatory lock, false for an advisory lock
+	Mandatory bool
+
+	// Type is the lock type
+	Type LockType
+
+	// PID is the lock owner process id, -1 for the OFD locks
+	PID int
+
+	// Major and Minor are the locked file device numbers
+	Major, Minor uint32
+
+	// Inode is the locked file inode number
+	Inode uint64
+
+	// Start and End are the locked byte range bounds. End is -1 for a range
+	// extending to the end of file.
+	Start, End int64
+}
+
+// SystemLocks returns the system wide locks on the lock file, found by its
+// device and inode numbers. The lock file is the open one, or the lock path
+// file if not open.
+func (lck *Lock) SystemLocks() ([]SystemLock, error) {
+	var st syscall.Stat_t
+	var err error
+	if lck.ReadWriteSeekCloser != nil {
+		err = syscall.Fstat(int(lck.fd), &st)
+	} else {
+		err = syscall.Stat(lck.path, &st)
+	}
+	if err != nil {
+		return nil, wrapPathErr(lck.path, "stat", err)
+	}
+	all, err := SystemLocks()
+	if err != nil {
+		return nil, err
+	}
+	major, minor := unix.Major(uint64(st.Dev)), unix.Minor(uint64(st.Dev))
+	var l []SystemLock
+	for _, sl := range all {
+		if sl.Inode == uint64(st.Ino) && sl.Major == major && sl.Minor == minor {
+			l = append(l, sl)
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,113 @@
+package fcntllock
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// procLocks is the kernel lock list, replaced by tests
+var procLocks = "/proc/locks"
+
+// SystemLocks returns the locks held or awaited on the system, parsed from
+// /proc/locks. Unlike F_GETLK, it also reports the locks of the calling
+// process.
+func SystemLocks() ([]SystemLock, error) {
+	f, err := os.Open(procLocks)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return parseProcLocks(f)
+}
+
+// parseProcLocks parses the /proc/locks lines, like:
+//
+//	1: POSIX  ADVISORY  WRITE 1234 08:01:131074 0 EOF
+//	1: -> POSIX  ADVISORY  WRITE 1235 08:01:131074 0 EOF
+func parseProcLocks(r io.Reader) ([]SystemLock, error) {
+	var l []SystemLock
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		sl, err := parseProcLock(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s line %q: %w", procLocks, line, err)
+		}
+		l = append(l, sl)
+	}
+	return l, scanner.Err()
+}
+
+func parseProcLock(line string) (sl SystemLock, err error) {
+	fields := strings.Fields(line)
+	if len(fields) > 1 && fields[1] == "->" {
+		sl.Blocked = true
+		fields = append(fields[:1], fields[2:]...)
+	}
+	if len(fields) != 8 {
+		return sl, fmt.Errorf("%d fields", len(fields))
+	}
+	if sl.ID, err = strconv.Atoi(strings.TrimSuffix(fields[0], ":")); err != nil {
+		return
+	}
+	sl.Class = fields[1]
+	sl.Mandatory = fields[2] == "MANDATORY"
+	switch fields[3] {
+	case "READ":
+		sl.Type = Shared
+	case "WRITE":
+		sl.Type = Exclusive
+	default:
+		sl.Type = LockType(unix.F_UNLCK)
+	}
+	if sl.PID, err = strconv.Atoi(fields[4]); err != nil {
+		return
+	}
+	if err = parseProcLockFile(fields[5], &sl); err != nil {
+		return
+	}
+	if sl.Start, err = strconv.ParseInt(fields[6], 10, 64); err != nil {
+		return
+	}
+	if fields[7] == "EOF" {
+		sl.End = -1
+	} else if sl.End, err = strconv.ParseInt(fields[7], 10, 64); err != nil {
+		return
+	}
+	return
+}
+
+// parseProcLockFile parses the "major:minor:inode" locked file field, the
+// device numbers being hexadecimal. The kernel writes "<none>:0" for a lock
+// without inode, leaving the sl file fields unset.
+func parseProcLockFile(s string, sl *SystemLock) error {
+	if strings.HasPrefix(s, "<none>") {
+		return nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return fmt.Errorf("invalid file %q", s)
+	}
+	major, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return err
+	}
+	minor, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return err
+	}
+	if sl.Inode, err = strconv.ParseUint(parts[2], 10, 64); err != nil {
+		return err
+	}
+	sl.Major, sl.Minor = uint32(major), uint32(minor)
+	return nil
+}
@@ -0,0 +1,61 @@
+package fcntllock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+const procLocksFixture = `1: POSIX  ADVISORY  WRITE 1234 08:01:131074 0 EOF
+1: -> POSIX  ADVISORY  WRITE 1235 08:01:131074 0 EOF
+2: OFDLCK ADVISORY  READ  -1 00:2e:98765 10 19
+3: FLOCK  ADVISORY  WRITE 567 fd:00:7890 0 EOF
+4: POSIX  MANDATORY READ  42 103:02:12 0 EOF
+`
+
+func TestParseProcLocks(t *testing.T) {
+	l, err := parseProcLocks(strings.NewReader(procLocksFixture))
+	require.NoError(t, err)
+	require.Equal(t, []SystemLock{
+		{ID: 1, Class: "POSIX", Type: Exclusive, PID: 1234, Major: 8, Minor: 1, Inode: 131074, End: -1},
+		{ID: 1, Blocked: true, Class: "POSIX", Type: Exclusive, PID: 1235, Major: 8, Minor: 1, Inode: 131074, End: -1},
+		{ID: 2, Class: "OFDLCK", Type: Shared, PID: -1, Major: 0, Minor: 0x2e, Inode: 98765, Start: 10, End: 19},
+		{ID: 3, Class: "FLOCK", Type: Exclusive, PID: 567, Major: 0xfd, Minor: 0, Inode: 7890, End: -1},
+		{ID: 4, Class: "POSIX", Mandatory: true, Type: Shared, PID: 42, Major: 0x103, Minor: 2, Inode: 12, End: -1},
+	}, l)
+
+	_, err = parseProcLocks(strings.NewReader("1: POSIX ADVISORY WRITE\n"))
+	require.Error(t, err)
+}
+
+func TestLockSystemLocks(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+	var st syscall.Stat_t
+	require.NoError(t, syscall.Stat(lockfile, &st))
+	major, minor := unix.Major(uint64(st.Dev)), unix.Minor(uint64(st.Dev))
+
+	fixture, err := ioutil.TempFile("", "locks")
+	require.NoError(t, err)
+	defer func() { _ = os.Remove(fixture.Name()) }()
+	_, err = fixture.WriteString(procLocksFixture)
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(fixture, "5: POSIX  ADVISORY  WRITE 4321 %x:%x:%d 0 EOF\n", major, minor, st.Ino)
+	require.NoError(t, err)
+	require.NoError(t, fixture.Close())
+	defer func(s string) { procLocks = s }(procLocks)
+	procLocks = fixture.Name()
+
+	l, err := New(lockfile).(*Lock).SystemLocks()
+	require.NoError(t, err)
+	require.Len(t, l, 1)
+	require.Equal(t, 5, l[0].ID)
+	require.Equal(t, 4321, l[0].PID)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package fcntllock
+
+// SystemLocks returns ErrNotSupported: the system wide lock list is only
+// read from the Linux /proc/locks.
+func SystemLocks() ([]SystemLock, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,53 @@
+package fcntllock
+
+import (
+	"context"
+	"syscall"
+	"time"
+)
+
+// LockOrTakeover acquires the lock with TryLock, or, if the lock is held by
+// someone else since more than maxAge, waits for the lock like LockWait until
+// ctx is done.
+//
+// The lock age is the lock file mtime, updated by LockOrTakeover on each
+// acquisition. Holders refreshing it, for example by a periodic write of the
+// lock file, are never considered stale. A fresh lock makes LockOrTakeover
+// return the TryLock contention error immediately.
+//
+// The takeover can't steal a lock: the kernel only grants it when the holder
+// releases it, which happens when a dead holder process exits. A live holder
+// with a stale mtime keeps the lock, and LockOrTakeover returns ctx.Err()
+// when ctx is done. The mtime is read without lock, so a holder acquiring the
+// lock between the TryLock and the mtime check may be seen stale, with the
+// same outcome.
+func (lck *Lock) LockOrTakeover(ctx context.Context, maxAge time.Duration) error {
+	err := lck.TryLock()
+	if err == nil || !lck.isContended(err) {
+		return lck.touchOnAcquired(err)
+	}
+	info, serr := stat(lck.path)
+	if serr != nil {
+		return wrapPathErr(lck.path, "stat", serr)
+	}
+	age := time.Since(info.ModTime())
+	if age < maxAge {
+		return err
+	}
+	lck.logf("lock held since %s, wait for the takeover", age)
+	return lck.touchOnAcquired(lck.LockWait(ctx))
+}
+
+// touchOnAcquired updates the lock file mtime if err, the acquisition error,
+// is nil, and returns err. A failed update is only logged, as the lock is
+// held anyway.
+func (lck *Lock) touchOnAcquired(err error) error {
+	if err != nil || lck.ReadWriteSeekCloser == nil {
+		return err
+	}
+	now := syscall.NsecToTimeval(time.Now().UnixNano())
+	if err := syscall.Futimes(int(lck.fd), []syscall.Timeval{now, now}); err != nil {
+		lck.logf("update lock file mtime: %s", err)
+	}
+	return nil
+}
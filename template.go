@@ -0,0 +1,34 @@
+package fcntllock
+
+import (
+	"syscall"
+)
+
+// WithCreateTemplate writes b at the beginning of the lock file when the
+// lock file is created by lck, for example a "# managed by myapp, do not
+// edit" warning.
+//
+// The template is written once the lock is first acquired, and only if the
+// file is still empty, so a reused lock file is never modified. The fcntl
+// locks apply to byte ranges whatever the file content, so the template
+// doesn't change the locked range: the whole file lock of lck covers it.
+func WithCreateTemplate(b []byte) Option {
+	return func(lck *Lock) {
+		lck.createTemplate = append([]byte{}, b...)
+	}
+}
+
+func (lck *Lock) writeTemplate() error {
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(lck.fd), &st); err != nil {
+		return err
+	}
+	lck.templatePending = false
+	if st.Size > 0 {
+		return lck.grow()
+	}
+	if _, err := syscall.Pwrite(int(lck.fd), lck.createTemplate, 0); err != nil {
+		return err
+	}
+	return lck.grow()
+}
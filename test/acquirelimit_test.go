@@ -0,0 +1,80 @@
+package fcntllock_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestSetMaxConcurrentAcquire(t *testing.T) {
+	fcntllock.SetMaxConcurrentAcquire(1)
+	defer fcntllock.SetMaxConcurrentAcquire(0)
+
+	setup := func(t *testing.T) (busy, free string, cleanup func()) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		return filepath.Join(lockDir, "busy"), filepath.Join(lockDir, "free"), cleanup
+	}
+
+	t.Run("second acquire waits for the first to complete", func(t *testing.T) {
+		busy, free, cleanup := setup(t)
+		defer cleanup()
+
+		// start in fork a lock and holds it during 102 milliseconds
+		forkCmd := lockInFork("TryLock", busy)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		first := fcntllock.New(busy).(*fcntllock.Lock)
+		done := first.AcquireAsync(context.Background(), 10*time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+
+		second := fcntllock.New(free)
+		t1 := time.Now()
+		require.NoError(t, second.LockContext(context.Background(), 10*time.Millisecond))
+		require.GreaterOrEqual(t, int64(time.Since(t1)), int64(20*time.Millisecond))
+		require.NoError(t, <-done)
+		require.NoError(t, forkCmd.Wait())
+		require.NoError(t, first.UnLock())
+		require.NoError(t, second.UnLock())
+	})
+
+	t.Run("free slot is taken with a done context", func(t *testing.T) {
+		_, free, cleanup := setup(t)
+		defer cleanup()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for i := 0; i < 20; i++ {
+			l := fcntllock.New(free).(*fcntllock.Lock)
+			require.NoError(t, l.LockContext(ctx, 10*time.Millisecond), "attempt %d", i)
+			require.NoError(t, l.UnLock())
+		}
+	})
+
+	t.Run("waiting for a slot respects the context", func(t *testing.T) {
+		busy, free, cleanup := setup(t)
+		defer cleanup()
+
+		forkCmd := lockInFork("TryLock", busy)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		first := fcntllock.New(busy).(*fcntllock.Lock)
+		done := first.AcquireAsync(context.Background(), 10*time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		second := fcntllock.New(free).(*fcntllock.Lock)
+		require.ErrorIs(t, second.LockContext(ctx, 10*time.Millisecond), context.DeadlineExceeded)
+		require.False(t, second.HeldByMe())
+		require.NoError(t, <-done)
+		require.NoError(t, forkCmd.Wait())
+		require.NoError(t, first.UnLock())
+	})
+}
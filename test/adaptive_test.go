@@ -0,0 +1,76 @@
+package fcntllock_test
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithAdaptiveDelay(t *testing.T) {
+	t.Run("without option the retry delay is used", func(t *testing.T) {
+		l := fcntllock.New("/tmp/lck").(*fcntllock.Lock)
+		require.Equal(t, 25*time.Millisecond, l.NextRetryDelay(25*time.Millisecond))
+	})
+
+	t.Run("sustained contention grows the delay to max, success resets it", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		min, max := 2*time.Millisecond, 16*time.Millisecond
+		l := fcntllock.New(lockfile, fcntllock.WithAdaptiveDelay(min, max)).(*fcntllock.Lock)
+		require.Equal(t, min, l.NextRetryDelay(time.Second))
+
+		// the forked lock process holds the lock until SIGUSR1
+		forkCmd := startLockInFork(t, "TryLockUntilSignal", lockfile)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		require.Error(t, l.LockContext(ctx, time.Second))
+		require.Equal(t, max, l.NextRetryDelay(time.Second), "delay is kept after timeout")
+
+		require.NoError(t, forkCmd.Process.Signal(syscall.SIGUSR1))
+		require.NoError(t, forkCmd.Wait())
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, l.LockContext(ctx, time.Second))
+		require.Equal(t, min, l.NextRetryDelay(time.Second), "delay is reset after success")
+		require.NoError(t, l.UnLock())
+	})
+}
+
+func TestLockContextDefault(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+
+	// lockDefault returns the LockContextDefault wait of the lock held 102
+	// milliseconds by a forked lock process
+	lockDefault := func(t *testing.T, l *fcntllock.Lock) time.Duration {
+		forkCmd := startLockInFork(t, "TryLock", lockfile)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		begin := time.Now()
+		require.NoError(t, l.LockContextDefault(ctx))
+		waited := time.Since(begin)
+		require.NoError(t, l.UnLock())
+		require.NoError(t, forkCmd.Wait())
+		return waited
+	}
+
+	t.Run("without SetRetryDelay the default delay is used", func(t *testing.T) {
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		waited := lockDefault(t, l)
+		require.GreaterOrEqual(t, int64(waited), int64(fcntllock.Defaults().RetryDelay))
+		require.Less(t, int64(waited), int64(time.Second))
+	})
+
+	t.Run("the SetRetryDelay delay is used", func(t *testing.T) {
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		l.SetRetryDelay(300 * time.Millisecond)
+		require.GreaterOrEqual(t, int64(lockDefault(t, l)), int64(300*time.Millisecond))
+	})
+}
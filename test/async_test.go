@@ -0,0 +1,60 @@
+package fcntllock_test
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestAcquireAsync(t *testing.T) {
+	t.Run("success after another process releases the lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// the forked lock process holds the lock 102 milliseconds
+		forkCmd := startLockInFork(t, "TryLock", lockfile)
+
+		done := l.AcquireAsync(context.Background(), 10*time.Millisecond)
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("acquisition not done")
+		}
+		_, ok := <-done
+		require.False(t, ok, "done must be closed")
+		require.True(t, l.HeldByMe())
+		require.NoError(t, forkCmd.Wait())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// the forked lock process holds the lock until SIGUSR1
+		forkCmd := startLockInFork(t, "TryLockUntilSignal", lockfile)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := l.AcquireAsync(ctx, 10*time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		select {
+		case err := <-done:
+			require.ErrorIs(t, err, context.Canceled)
+		case <-time.After(time.Second):
+			t.Fatal("cancellation not delivered")
+		}
+		require.False(t, l.HeldByMe())
+		require.NoError(t, forkCmd.Process.Signal(syscall.SIGUSR1))
+		require.NoError(t, forkCmd.Wait())
+	})
+}
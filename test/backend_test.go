@@ -0,0 +1,157 @@
+package fcntllock_test
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+// memBackend is an in-memory lock backend, where each Lock is a distinct
+// owner
+type memBackend struct {
+	mu     sync.Mutex
+	owners map[string]*fcntllock.Lock
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{owners: make(map[string]*fcntllock.Lock)}
+}
+
+func (b *memBackend) TryAcquire(l *fcntllock.Lock, typ fcntllock.LockType) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if owner, ok := b.owners[l.Path()]; ok && owner != l {
+		return fcntllock.ErrBusy
+	}
+	b.owners[l.Path()] = l
+	return nil
+}
+
+func (b *memBackend) Release(l *fcntllock.Lock) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.owners[l.Path()] == l {
+		delete(b.owners, l.Path())
+	}
+	return nil
+}
+
+func (b *memBackend) Probe(path string) (fcntllock.LockStatus, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, held := b.owners[path]
+	return fcntllock.LockStatus{Path: path, Held: held, Type: fcntllock.Exclusive}, nil
+}
+
+func TestWithBackend(t *testing.T) {
+	b := newMemBackend()
+	path := "/no-such-dir/mem.lock"
+	l1 := fcntllock.New(path, fcntllock.WithBackend(b)).(*fcntllock.Lock)
+	l2 := fcntllock.New(path, fcntllock.WithBackend(b)).(*fcntllock.Lock)
+
+	t.Run("TryLock conflicts between owners", func(t *testing.T) {
+		require.NoError(t, l1.TryLock())
+		require.True(t, l1.HeldByMe())
+		require.ErrorIs(t, l2.TryLock(), fcntllock.ErrBusy)
+		require.False(t, l2.HeldByMe())
+		status, err := l2.Status()
+		require.NoError(t, err)
+		require.True(t, status.Held)
+	})
+
+	t.Run("LockContext waits for the release", func(t *testing.T) {
+		go func() {
+			time.Sleep(30 * time.Millisecond)
+			_ = l1.UnLock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, l2.LockContext(ctx, 10*time.Millisecond))
+		require.True(t, l2.HeldByMe())
+		require.False(t, l1.HeldByMe())
+	})
+
+	t.Run("LockContext times out", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, l1.LockContext(ctx, 10*time.Millisecond), context.DeadlineExceeded)
+	})
+
+	t.Run("blocking Lock is not supported", func(t *testing.T) {
+		require.ErrorIs(t, l1.Lock(), fcntllock.ErrNotSupported)
+	})
+
+	t.Run("UnLock", func(t *testing.T) {
+		require.NoError(t, l2.UnLock())
+		require.False(t, l2.HeldByMe())
+		status, err := l1.Status()
+		require.NoError(t, err)
+		require.False(t, status.Held)
+	})
+}
+
+func TestFcntlBackend(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	l := fcntllock.New(lockfile, fcntllock.WithBackend(fcntllock.FcntlBackend())).(*fcntllock.Lock)
+	require.NoError(t, l.TryLock())
+	require.False(t, rangeLockable(t, lockfile, 0, 0))
+	status, err := l.Status()
+	require.NoError(t, err)
+	require.False(t, status.Held, "the own fcntl locks are never reported")
+	require.NoError(t, l.UnLock())
+	require.True(t, rangeLockable(t, lockfile, 0, 0))
+}
+
+func TestBackendLockFileMethods(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	content, err := ioutil.ReadFile(lockfile)
+	require.NoError(t, err)
+	l := fcntllock.New(lockfile, fcntllock.WithBackend(newMemBackend())).(*fcntllock.Lock)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	t.Run("Update", func(t *testing.T) {
+		called := false
+		err := l.Update(ctx, 10*time.Millisecond, func(old []byte) ([]byte, error) {
+			called = true
+			return []byte("new"), nil
+		})
+		require.ErrorIs(t, err, fcntllock.ErrNoFd)
+		require.False(t, called)
+		require.False(t, l.HeldByMe(), "the lock must be released")
+	})
+
+	require.NoError(t, l.TryLock())
+	defer func() { _ = l.UnLock() }()
+
+	t.Run("CompareAndSwap", func(t *testing.T) {
+		swapped, err := l.CompareAndSwap(nil, []byte("new"))
+		require.ErrorIs(t, err, fcntllock.ErrNoFd)
+		require.False(t, swapped)
+	})
+
+	t.Run("Revalidate", func(t *testing.T) {
+		require.ErrorIs(t, l.Revalidate(), fcntllock.ErrNoFd)
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		require.ErrorIs(t, l.Reset(), fcntllock.ErrNoFd)
+	})
+
+	t.Run("RepairMetadata", func(t *testing.T) {
+		require.ErrorIs(t, l.RepairMetadata(), fcntllock.ErrNoFd)
+	})
+
+	b, err := ioutil.ReadFile(lockfile)
+	require.NoError(t, err)
+	require.Equal(t, content, b, "the lock file must not be written")
+}
@@ -0,0 +1,100 @@
+package fcntllock_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	t.Run("swap", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, []byte("version=1\nlonger payload"), 0600))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+
+		swapped, err := l.CompareAndSwap([]byte("version=1\nlonger payload"), []byte("version=2\n"))
+		require.NoError(t, err)
+		require.True(t, swapped)
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "version=2\n", string(b), "the previous content must be truncated")
+	})
+
+	t.Run("swap from empty", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, nil, 0600))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+
+		swapped, err := l.CompareAndSwap(nil, []byte("init"))
+		require.NoError(t, err)
+		require.True(t, swapped)
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "init", string(b))
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, []byte("version=3\n"), 0600))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+
+		swapped, err := l.CompareAndSwap([]byte("version=1\n"), []byte("version=2\n"))
+		require.NoError(t, err)
+		require.False(t, swapped)
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "version=3\n", string(b), "the content must not be written")
+	})
+
+	t.Run("not held", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		_, err := l.CompareAndSwap(nil, []byte("x"))
+		require.ErrorIs(t, err, fcntllock.ErrNotLocked)
+	})
+}
+
+func TestLockIf(t *testing.T) {
+	notDone := func(b []byte) bool { return !bytes.Contains(b, []byte("status=done")) }
+
+	t.Run("predicate passes, the lock is kept", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, []byte("status=running\n"), 0600))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.LockIf(context.Background(), 10*time.Millisecond, notDone))
+		require.True(t, l.HeldByMe())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("predicate fails, the lock is released", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, []byte("status=done\n"), 0600))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.ErrorIs(t, l.LockIf(context.Background(), 10*time.Millisecond, notDone), fcntllock.ErrPredicateFailed)
+		require.False(t, l.HeldByMe())
+
+		// another process can get the lock
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		require.NoError(t, forkCmd.Wait())
+	})
+}
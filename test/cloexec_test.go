@@ -0,0 +1,40 @@
+package fcntllock_test
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func fdCloseOnExec(t *testing.T, l *fcntllock.Lock) bool {
+	t.Helper()
+	fd := l.ReadWriteSeekCloser.(interface{ Fd() uintptr }).Fd()
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFD, 0)
+	require.Zero(t, errno)
+	return flags&syscall.FD_CLOEXEC != 0
+}
+
+func TestWithCloseOnExec(t *testing.T) {
+	cases := map[string]struct {
+		opts     []fcntllock.Option
+		expected bool
+	}{
+		"default is close-on-exec":         {expected: true},
+		"WithCloseOnExec(true)":            {opts: []fcntllock.Option{fcntllock.WithCloseOnExec(true)}, expected: true},
+		"WithCloseOnExec(false) clears it": {opts: []fcntllock.Option{fcntllock.WithCloseOnExec(false)}, expected: false},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			lockfile, tfCleanup := testhelper.TempFile(t)
+			defer tfCleanup()
+			l := fcntllock.New(lockfile, c.opts...).(*fcntllock.Lock)
+			require.NoError(t, l.TryLock())
+			defer func() { _ = l.UnLock() }()
+			require.Equal(t, c.expected, fdCloseOnExec(t, l))
+		})
+	}
+}
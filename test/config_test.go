@@ -0,0 +1,77 @@
+package fcntllock_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("default config", func(t *testing.T) {
+		c := fcntllock.New("/var/lock/lck").(*fcntllock.Lock).Config()
+		require.Equal(t, "/var/lock/lck", c.Path)
+		require.True(t, c.CloseOnExec)
+		require.True(t, c.CreateDir)
+		require.True(t, c.EACCESAsContention)
+		require.Equal(t, 5, c.ENOLCKRetries)
+		require.Equal(t, c, fcntllock.NewFromConfig(c).(*fcntllock.Lock).Config())
+	})
+
+	t.Run("round trip through json", func(t *testing.T) {
+		l := fcntllock.New("/var/lock/lck",
+			fcntllock.WithSuffix(".lock"),
+			fcntllock.WithOFD(true),
+			fcntllock.WithBestEffort(true),
+			fcntllock.WithReentrant(true),
+			fcntllock.WithRegularFileOnly(true),
+			fcntllock.WithRefuseHardlinks(true),
+			fcntllock.WithNoFollow(true),
+			fcntllock.WithRequireOwner(0),
+			fcntllock.WithInodeCheck(true),
+			fcntllock.WithCloseOnExec(false),
+			fcntllock.WithCreateDir(false),
+			fcntllock.WithEnsureDirOnPermError(true),
+			fcntllock.WithEACCESAsContention(false),
+			fcntllock.WithENOLCKRetries(9),
+			fcntllock.WithInaccessiblePolicy(fcntllock.InaccessibleAsError),
+			fcntllock.WithAdaptiveDelay(time.Millisecond, time.Second),
+			fcntllock.WithFcntlTimeout(3*time.Second),
+			fcntllock.WithFIFO(time.Minute),
+			fcntllock.WithInitialSpin(3),
+			fcntllock.WithExitOnOrphan(3),
+			fcntllock.WithContentionCounter(),
+			fcntllock.WithWaitBuckets([]time.Duration{time.Millisecond, time.Second}),
+			fcntllock.WithCreateTemplate([]byte("# do not edit\n")),
+			fcntllock.WithTruncateOnLock(true),
+			fcntllock.WithMinSize(4096),
+			fcntllock.WithForceCopyUp(true),
+			fcntllock.WithAtomicCreate(true),
+			fcntllock.WithIdentity("tenant-42/billing"),
+			fcntllock.WithRecordWait(),
+			fcntllock.WithLease(10*time.Second),
+		).(*fcntllock.Lock)
+		c := l.Config()
+		require.Equal(t, "/var/lock/lck.lock", c.Path)
+		require.True(t, c.Metadata, "WithLease implies WithMetadata")
+
+		b, err := json.Marshal(c)
+		require.NoError(t, err)
+		var decoded fcntllock.LockConfig
+		require.NoError(t, json.Unmarshal(b, &decoded))
+		require.Equal(t, c, decoded)
+
+		rebuilt := fcntllock.NewFromConfig(decoded).(*fcntllock.Lock)
+		require.Equal(t, c, rebuilt.Config())
+		require.Equal(t, l.Path(), rebuilt.Path())
+	})
+
+	t.Run("options are applied after the config", func(t *testing.T) {
+		c := fcntllock.LockConfig{Path: "/var/lock/lck", ENOLCKRetries: 5}
+		l := fcntllock.NewFromConfig(c, fcntllock.WithENOLCKRetries(1)).(*fcntllock.Lock)
+		require.Equal(t, 1, l.Config().ENOLCKRetries)
+	})
+}
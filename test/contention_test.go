@@ -0,0 +1,44 @@
+package fcntllock_test
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestContentionDepth(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	defer func() { _ = os.Remove(lockfile + ".waiters") }()
+	l := fcntllock.New(lockfile, fcntllock.WithContentionCounter()).(*fcntllock.Lock)
+
+	depth, err := l.ContentionDepth()
+	require.NoError(t, err)
+	require.Equal(t, 0, depth, "no counter file means no waiter")
+
+	require.NoError(t, l.TryLock())
+	var waiters []*exec.Cmd
+	for i := 0; i < 3; i++ {
+		forkCmd := lockInFork("LockContextCounted", lockfile)
+		require.NoError(t, forkCmd.Start())
+		waiters = append(waiters, forkCmd)
+	}
+	time.Sleep(200 * time.Millisecond)
+	depth, err = l.ContentionDepth()
+	require.NoError(t, err)
+	require.Equal(t, 3, depth)
+
+	require.NoError(t, l.UnLock())
+	for _, forkCmd := range waiters {
+		require.NoError(t, forkCmd.Wait())
+	}
+	depth, err = l.ContentionDepth()
+	require.NoError(t, err)
+	require.Equal(t, 0, depth)
+}
@@ -0,0 +1,50 @@
+package fcntllock_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithCreateDir(t *testing.T) {
+	t.Run("missing directory is created by default", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "missing", "lck")
+		l := fcntllock.New(lockfile, fcntllock.WithCreateDir(true))
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.UnLock())
+		require.DirExists(t, filepath.Dir(lockfile))
+	})
+
+	t.Run("missing directory is not created when disabled", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "missing", "lck")
+		l := fcntllock.New(lockfile, fcntllock.WithCreateDir(false)).(*fcntllock.Lock)
+		require.ErrorIs(t, l.TryLock(), os.ErrNotExist)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		t1 := time.Now()
+		require.ErrorIs(t, l.LockContext(ctx, 10*time.Millisecond), os.ErrNotExist)
+		require.Less(t, int64(time.Since(t1)), int64(10*time.Millisecond))
+		require.False(t, l.HeldByMe())
+		require.NoDirExists(t, filepath.Dir(lockfile))
+	})
+
+	t.Run("existing directory is used when disabled", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithCreateDir(false))
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.UnLock())
+	})
+}
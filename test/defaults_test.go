@@ -0,0 +1,36 @@
+package fcntllock_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestDefaults(t *testing.T) {
+	d := fcntllock.Defaults()
+	require.Equal(t, os.FileMode(0700), d.LockDirPerm)
+	require.Equal(t, os.FileMode(0666), d.LockFilePerm)
+	require.NotZero(t, d.LockFileFlags&os.O_CREATE)
+	require.NotZero(t, d.LockFileFlags&os.O_RDWR)
+	require.Equal(t, 5, d.ENOLCKRetries)
+	require.Equal(t, 0, d.MaxConcurrentAcquire)
+	require.Equal(t, 0, d.MaxOpenLocks)
+	require.Equal(t, 100*time.Millisecond, d.RetryDelay)
+
+	fcntllock.SetDefaultLockDirPerm(0750)
+	defer fcntllock.SetDefaultLockDirPerm(d.LockDirPerm)
+	fcntllock.SetMaxConcurrentAcquire(4)
+	defer fcntllock.SetMaxConcurrentAcquire(0)
+	fcntllock.SetMaxOpenLocks(64)
+	defer fcntllock.SetMaxOpenLocks(0)
+
+	changed := fcntllock.Defaults()
+	require.Equal(t, os.FileMode(0750), changed.LockDirPerm)
+	require.Equal(t, 4, changed.MaxConcurrentAcquire)
+	require.Equal(t, 64, changed.MaxOpenLocks)
+	require.Equal(t, d.LockFileFlags, changed.LockFileFlags)
+}
@@ -0,0 +1,56 @@
+package fcntllock_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithEnsureDirOnPermError(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+
+	t.Run("without option the permission error is returned", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		defer func() { _ = os.Chmod(lockDir, 0700) }()
+		require.NoError(t, os.Chmod(lockDir, 0500))
+		l := fcntllock.New(filepath.Join(lockDir, "lck"))
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, l.LockContext(ctx, 10*time.Millisecond), os.ErrPermission)
+	})
+
+	t.Run("directory permissions toggled during retry are restored", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		defer func() { _ = os.Chmod(lockDir, 0700) }()
+		lockfile := filepath.Join(lockDir, "lck")
+		l := fcntllock.New(lockfile, fcntllock.WithEnsureDirOnPermError(true))
+
+		// the forked lock process holds the lock 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(30 * time.Millisecond)
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			_ = os.Chmod(lockDir, 0)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, l.LockContext(ctx, 10*time.Millisecond))
+		require.NoError(t, forkCmd.Wait())
+		info, err := os.Stat(lockDir)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	})
+}
@@ -0,0 +1,154 @@
+package fcntllock_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestErrorsHaveLockPath(t *testing.T) {
+	t.Run("lock dir creation error", func(t *testing.T) {
+		tf, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		lockfile := filepath.Join(tf, "dir", "lck")
+		err := fcntllock.New(lockfile).TryLock()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "fcntllock "+lockfile+": ")
+		require.Contains(t, err.Error(), "/dir: not a directory")
+	})
+
+	t.Run("contended lock error", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+		err := fcntllock.New(lockfile).TryLock()
+		require.NoError(t, forkCmd.Wait())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "fcntllock "+lockfile+": ")
+		var errno syscall.Errno
+		require.True(t, errors.As(err, &errno))
+	})
+
+	t.Run("open error", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "lck")
+		l := fcntllock.New(lockfile, fcntllock.WithRegularFileOnly(true))
+		require.NoError(t, os.Mkdir(lockfile, 0700))
+		err := l.TryLock()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "fcntllock "+lockfile+": ")
+		var pathErr *os.PathError
+		require.True(t, errors.As(err, &pathErr))
+	})
+
+	t.Run("unlock error", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile)
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.Close())
+		err := l.UnLock()
+		require.ErrorIs(t, err, syscall.EBADF)
+		require.Contains(t, err.Error(), "fcntllock "+lockfile+": ")
+	})
+}
+
+func TestLockError(t *testing.T) {
+	requireLockError := func(t *testing.T, err error, path, op string) *fcntllock.LockError {
+		t.Helper()
+		var lerr *fcntllock.LockError
+		require.True(t, errors.As(err, &lerr), "not a *LockError: %s", err)
+		require.Equal(t, path, lerr.Path)
+		require.Equal(t, op, lerr.Op)
+		require.Contains(t, lerr.Error(), path)
+		return lerr
+	}
+
+	t.Run("mkdir", func(t *testing.T) {
+		tf, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		lockfile := filepath.Join(tf, "dir", "lck")
+		err := fcntllock.New(lockfile).TryLock()
+		requireLockError(t, err, lockfile, "mkdir")
+	})
+
+	t.Run("open", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "lck")
+		require.NoError(t, os.Mkdir(lockfile, 0700))
+		err := fcntllock.New(lockfile, fcntllock.WithRegularFileOnly(true)).TryLock()
+		requireLockError(t, err, lockfile, "open")
+		require.ErrorIs(t, err, syscall.EISDIR)
+	})
+
+	t.Run("lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+		err := fcntllock.New(lockfile).TryLock()
+		require.NoError(t, forkCmd.Wait())
+		lerr := requireLockError(t, err, lockfile, "lock")
+		var errno syscall.Errno
+		require.True(t, errors.As(lerr.Err, &errno))
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile)
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.Close())
+		err := l.UnLock()
+		requireLockError(t, err, lockfile, "unlock")
+		require.ErrorIs(t, err, syscall.EBADF)
+	})
+
+	t.Run("hook", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		hookErr := errors.New("hook failed")
+		l := fcntllock.New(lockfile, fcntllock.WithPostAcquire(func(*fcntllock.Lock) error { return hookErr }))
+		err := l.TryLock()
+		requireLockError(t, err, lockfile, "hook")
+		require.ErrorIs(t, err, hookErr)
+	})
+
+	t.Run("revalidate", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		require.NoError(t, os.Remove(lockfile))
+		err := l.Revalidate()
+		requireLockError(t, err, lockfile, "revalidate")
+		require.ErrorIs(t, err, fcntllock.ErrLockLost)
+	})
+
+	t.Run("single path prefix", func(t *testing.T) {
+		tf, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		lockfile := filepath.Join(tf, "dir", "lck")
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := fcntllock.New(lockfile).(*fcntllock.Lock).LockContext(ctx, 10*time.Millisecond)
+		requireLockError(t, err, lockfile, "mkdir")
+		require.Equal(t, 1, strings.Count(err.Error(), "fcntllock "))
+	})
+}
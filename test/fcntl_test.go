@@ -0,0 +1,47 @@
+package fcntllock_test
+
+import (
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestFcntl(t *testing.T) {
+	t.Run("manual F_GETLK probe of a free lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		defer func() { _ = l.Close() }()
+		ft := &syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekStart}
+		require.NoError(t, l.Fcntl(syscall.F_GETLK, ft))
+		require.Equal(t, int16(syscall.F_UNLCK), ft.Type)
+	})
+
+	t.Run("manual F_GETLK probe of a range held in fork", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		forkCmd := lockInFork("TryRLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		defer func() { _ = l.Close() }()
+		ft := &syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekStart, Start: 10, Len: 5}
+		require.NoError(t, l.Fcntl(syscall.F_GETLK, ft))
+		require.Equal(t, int16(syscall.F_RDLCK), ft.Type)
+		require.Equal(t, int32(forkCmd.Process.Pid), ft.Pid)
+		require.False(t, l.HeldByMe(), "Fcntl bypasses the state tracking")
+
+		// a shared lock request is compatible
+		ft = &syscall.Flock_t{Type: syscall.F_RDLCK, Whence: io.SeekStart}
+		require.NoError(t, l.Fcntl(syscall.F_GETLK, ft))
+		require.Equal(t, int16(syscall.F_UNLCK), ft.Type)
+		require.NoError(t, forkCmd.Wait())
+	})
+}
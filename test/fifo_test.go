@@ -0,0 +1,109 @@
+package fcntllock_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithFIFO(t *testing.T) {
+	setup := func(t *testing.T) (lockfile, orderfile string, cleanup func()) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		return filepath.Join(lockDir, "lck"), filepath.Join(lockDir, "order"), cleanup
+	}
+	readOrder := func(t *testing.T, orderfile string) []string {
+		b, err := ioutil.ReadFile(orderfile)
+		require.NoError(t, err)
+		return strings.Fields(string(b))
+	}
+
+	t.Run("contenders are granted in arrival order", func(t *testing.T) {
+		lockfile, orderfile, cleanup := setup(t)
+		defer cleanup()
+		l := fcntllock.New(lockfile)
+		require.NoError(t, l.TryLock())
+
+		var contenders []*exec.Cmd
+		for i := 0; i < 4; i++ {
+			forkCmd := lockInFork("LockContextFIFO", lockfile, "10s", orderfile, strconv.Itoa(i))
+			require.NoError(t, forkCmd.Start())
+			contenders = append(contenders, forkCmd)
+			// let the contender register before the next one
+			time.Sleep(50 * time.Millisecond)
+		}
+		require.NoError(t, l.UnLock())
+		for _, forkCmd := range contenders {
+			require.NoError(t, forkCmd.Wait())
+		}
+		require.Equal(t, []string{"0", "1", "2", "3"}, readOrder(t, orderfile))
+	})
+
+	t.Run("crashed contender does not block the queue", func(t *testing.T) {
+		lockfile, orderfile, cleanup := setup(t)
+		defer cleanup()
+		l := fcntllock.New(lockfile)
+		require.NoError(t, l.TryLock())
+
+		crashed := lockInFork("LockContextFIFO", lockfile, "10s", orderfile, "crashed")
+		require.NoError(t, crashed.Start())
+		time.Sleep(50 * time.Millisecond)
+		next := lockInFork("LockContextFIFO", lockfile, "10s", orderfile, "next")
+		require.NoError(t, next.Start())
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, crashed.Process.Kill())
+		_ = crashed.Wait()
+
+		require.NoError(t, l.UnLock())
+		begin := time.Now()
+		require.NoError(t, next.Wait())
+		require.Less(t, int64(time.Since(begin)), int64(time.Second))
+		require.Equal(t, []string{"next"}, readOrder(t, orderfile))
+	})
+
+	t.Run("hung contender is skipped after the timeout", func(t *testing.T) {
+		lockfile, orderfile, cleanup := setup(t)
+		defer cleanup()
+		l := fcntllock.New(lockfile)
+		require.NoError(t, l.TryLock())
+
+		hung := lockInFork("LockContextFIFO", lockfile, "10s", orderfile, "hung")
+		require.NoError(t, hung.Start())
+		defer func() {
+			_ = hung.Process.Kill()
+			_ = hung.Wait()
+		}()
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, hung.Process.Signal(syscall.SIGSTOP))
+		next := lockInFork("LockContextFIFO", lockfile, "100ms", orderfile, "next")
+		require.NoError(t, next.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		require.NoError(t, l.UnLock())
+		require.NoError(t, next.Wait())
+		require.Equal(t, []string{"next"}, readOrder(t, orderfile))
+	})
+
+	t.Run("sequence file is a sidecar", func(t *testing.T) {
+		lockfile, _, cleanup := setup(t)
+		defer cleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithFIFO(time.Second))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, l.LockContext(ctx, 10*time.Millisecond))
+		require.NoError(t, l.UnLock())
+		_, err := os.Stat(lockfile + ".fifo")
+		require.NoError(t, err)
+	})
+}
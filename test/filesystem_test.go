@@ -0,0 +1,30 @@
+package fcntllock_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestFilesystem(t *testing.T) {
+	t.Run("local lock file", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		fstype, isNetwork, err := fcntllock.New(lockfile).(*fcntllock.Lock).Filesystem()
+		require.NoError(t, err)
+		require.NotEmpty(t, fstype)
+		require.False(t, isNetwork)
+	})
+
+	t.Run("lock file not yet created", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		fstype, _, err := fcntllock.New(filepath.Join(lockDir, "lck")).(*fcntllock.Lock).Filesystem()
+		require.NoError(t, err)
+		require.NotEmpty(t, fstype)
+	})
+}
@@ -0,0 +1,76 @@
+package fcntllock_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestRunWithLock(t *testing.T) {
+	fnErr := errors.New("fn error")
+
+	cases := []struct {
+		name       string
+		forkCmd    string
+		opts       fcntllock.FlockOpts
+		called     bool
+		exitCode   int
+		minElapsed time.Duration
+	}{
+		{name: "exclusive on free lock", opts: fcntllock.FlockOpts{}, called: true},
+		{name: "shared on free lock", opts: fcntllock.FlockOpts{Shared: true}, called: true},
+		{name: "exclusive waits for exclusive holder", forkCmd: "TryLock", opts: fcntllock.FlockOpts{},
+			called: true, minElapsed: 20 * time.Millisecond},
+		{name: "shared waits for exclusive holder", forkCmd: "TryLock", opts: fcntllock.FlockOpts{Shared: true},
+			called: true, minElapsed: 20 * time.Millisecond},
+		{name: "shared with shared holder", forkCmd: "TryRLock", opts: fcntllock.FlockOpts{Shared: true, NonBlock: true},
+			called: true},
+		{name: "nonblocking exclusive with shared holder", forkCmd: "TryRLock", opts: fcntllock.FlockOpts{NonBlock: true},
+			exitCode: 1},
+		{name: "nonblocking shared with exclusive holder", forkCmd: "TryLock", opts: fcntllock.FlockOpts{Shared: true, NonBlock: true},
+			exitCode: 1},
+		{name: "nonblocking with conflict exit code", forkCmd: "TryLock", opts: fcntllock.FlockOpts{NonBlock: true, ConflictExitCode: 42},
+			exitCode: 42},
+		{name: "timeout reached", forkCmd: "TryLock", opts: fcntllock.FlockOpts{Timeout: 20 * time.Millisecond},
+			exitCode: 1},
+		{name: "timeout not reached", forkCmd: "TryLock", opts: fcntllock.FlockOpts{Timeout: time.Second},
+			called: true, minElapsed: 20 * time.Millisecond},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lockfile, tfCleanup := testhelper.TempFile(t)
+			defer tfCleanup()
+			l := fcntllock.New(lockfile).(*fcntllock.Lock)
+			if c.forkCmd != "" {
+				// start in fork a lock and holds it during 102 milliseconds
+				forkCmd := lockInFork(c.forkCmd, lockfile)
+				require.NoError(t, forkCmd.Start())
+				defer func() { require.NoError(t, forkCmd.Wait()) }()
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			called := false
+			t1 := time.Now()
+			err := l.RunWithLock(c.opts, func() error {
+				called = true
+				require.True(t, l.HeldByMe())
+				return fnErr
+			})
+			require.Equal(t, c.called, called)
+			if c.called {
+				require.ErrorIs(t, err, fnErr)
+				require.False(t, l.HeldByMe(), "lock must be released after fn")
+				require.GreaterOrEqual(t, int64(time.Since(t1)), int64(c.minElapsed))
+			} else {
+				var conflictErr *fcntllock.ConflictError
+				require.ErrorAs(t, err, &conflictErr)
+				require.Equal(t, c.exitCode, conflictErr.ExitCode)
+			}
+		})
+	}
+}
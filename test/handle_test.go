@@ -0,0 +1,35 @@
+package fcntllock_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestHandle(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	l := fcntllock.New(lockfile).(*fcntllock.Lock)
+	require.NoError(t, l.TryLock())
+
+	var closer io.Closer = l.Handle()
+
+	forkCmd := lockInFork("TryLock", lockfile)
+	require.NoError(t, forkCmd.Start())
+	require.Error(t, forkCmd.Wait(), "expected lock to be held")
+
+	require.NoError(t, closer.Close())
+	require.False(t, l.HeldByMe())
+	forkCmd = lockInFork("TryLock", lockfile)
+	require.NoError(t, forkCmd.Start())
+	require.NoError(t, forkCmd.Wait(), "expected lock to be released")
+
+	require.NoError(t, closer.Close(), "double close must be safe")
+
+	require.NoError(t, l.TryLock(), "lock can be acquired again after handle close")
+	require.NoError(t, l.UnLock())
+}
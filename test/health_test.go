@@ -0,0 +1,45 @@
+package fcntllock_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestHealthCheck(t *testing.T) {
+	t.Run("healthy directory", func(t *testing.T) {
+		dir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		require.NoError(t, fcntllock.HealthCheck(dir))
+		entries, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, entries, "the temporary lock file must be removed")
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		dir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		err := fcntllock.HealthCheck(filepath.Join(dir, "missing"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+		require.Contains(t, err.Error(), "create lock file")
+	})
+
+	t.Run("permission denied directory", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("permissions are not enforced for root")
+		}
+		dir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		defer func() { _ = os.Chmod(dir, 0700) }()
+		require.NoError(t, os.Chmod(dir, 0500))
+		err := fcntllock.HealthCheck(dir)
+		require.ErrorIs(t, err, os.ErrPermission)
+		require.Contains(t, err.Error(), "fcntllock health check "+dir+": create lock file")
+	})
+}
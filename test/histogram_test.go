@@ -0,0 +1,47 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWaitHistogram(t *testing.T) {
+	t.Run("is nil without buckets", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.Nil(t, l.WaitHistogram())
+	})
+
+	t.Run("waits land in the right buckets", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		buckets := []time.Duration{200 * time.Millisecond, 10 * time.Millisecond}
+		l := fcntllock.New(lockfile, fcntllock.WithWaitBuckets(buckets)).(*fcntllock.Lock)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// uncontended
+		require.NoError(t, l.LockContext(ctx, 5*time.Millisecond))
+		require.NoError(t, l.UnLock())
+
+		// contended, the forked lock process holds the lock 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, l.LockContext(ctx, 5*time.Millisecond))
+		require.NoError(t, forkCmd.Wait())
+
+		require.Equal(t, map[time.Duration]int{
+			10 * time.Millisecond:  1,
+			200 * time.Millisecond: 1,
+			fcntllock.InfBucket:    0,
+		}, l.WaitHistogram())
+	})
+}
@@ -0,0 +1,46 @@
+package fcntllock_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestSignalHolderMetadata(t *testing.T) {
+	// the OFD lock of the process is seen by its F_GETLK probe, without pid,
+	// so the holder pid is read from the metadata
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	l := fcntllock.New(lockfile, fcntllock.WithOFD(true), fcntllock.WithMetadata()).(*fcntllock.Lock)
+	require.NoError(t, l.TryLock())
+	defer func() { _ = l.UnLock() }()
+
+	t.Run("local holder", func(t *testing.T) {
+		require.NoError(t, fcntllock.SignalHolder(lockfile, syscall.Signal(0)))
+	})
+
+	t.Run("holder on another host", func(t *testing.T) {
+		current, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		other := fmt.Sprintf("pid=%d\nhost=not-%s\nacquired=%s\n", os.Getpid(), hostname(t), time.Now().Format(time.RFC3339Nano))
+		swapped, err := l.CompareAndSwap(current, []byte(other))
+		require.NoError(t, err)
+		require.True(t, swapped)
+		require.ErrorIs(t, fcntllock.SignalHolder(lockfile, syscall.Signal(0)), fcntllock.ErrHolderUnknown)
+	})
+}
+
+func hostname(t *testing.T) string {
+	t.Helper()
+	host, err := os.Hostname()
+	require.NoError(t, err)
+	return host
+}
@@ -0,0 +1,36 @@
+package fcntllock_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestSignalHolder(t *testing.T) {
+	t.Run("the signal reaches the holder", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+
+		// the forked lock process holds the lock until SIGUSR1
+		forkCmd := lockInFork("TryLockUntilSignal", lockfile)
+		require.NoError(t, forkCmd.Start())
+		require.Eventually(t, func() bool {
+			status, err := fcntllock.Probe(lockfile)
+			return err == nil && status.Held
+		}, time.Second, 5*time.Millisecond)
+
+		require.NoError(t, fcntllock.SignalHolder(lockfile, syscall.SIGUSR1))
+		require.NoError(t, forkCmd.Wait(), "the holder exits on signal")
+	})
+
+	t.Run("free lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.ErrorIs(t, fcntllock.SignalHolder(lockfile, syscall.SIGUSR1), fcntllock.ErrNoHolder)
+	})
+}
@@ -0,0 +1,56 @@
+package fcntllock_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithPostAcquire(t *testing.T) {
+	t.Run("hook runs while holding the lock and lock is retained", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		var content []byte
+		hook := func(l *fcntllock.Lock) error {
+			require.True(t, l.HeldByMe())
+			var err error
+			content, err = ioutil.ReadAll(l)
+			return err
+		}
+		l := fcntllock.New(lockfile, fcntllock.WithPostAcquire(hook)).(*fcntllock.Lock)
+		require.NoError(t, l.LockContext(context.Background(), 10*time.Millisecond))
+		require.True(t, l.HeldByMe())
+		require.Equal(t, "#!/bin/bash\n", string(content))
+
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		require.Error(t, forkCmd.Wait(), "expected lock to be retained")
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("hook failure releases the lock and returns the error", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		hookErr := errors.New("hook error")
+		calls := 0
+		hook := func(l *fcntllock.Lock) error {
+			calls++
+			return hookErr
+		}
+		l := fcntllock.New(lockfile, fcntllock.WithPostAcquire(hook)).(*fcntllock.Lock)
+		require.ErrorIs(t, l.LockContext(context.Background(), 10*time.Millisecond), hookErr)
+		require.Equal(t, 1, calls, "hook error must not be retried")
+		require.False(t, l.HeldByMe())
+
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		require.NoError(t, forkCmd.Wait(), "expected lock to be released")
+	})
+}
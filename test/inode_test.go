@@ -0,0 +1,72 @@
+package fcntllock_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+// replaceFile replaces path by a new file, with a new inode
+func replaceFile(t *testing.T, path string) {
+	tmp := path + ".new"
+	require.NoError(t, ioutil.WriteFile(tmp, nil, 0600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestWithInodeCheck(t *testing.T) {
+	t.Run("unchanged lock file", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithInodeCheck(true)).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.Verify())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("replaced lock file is detected", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithInodeCheck(true)).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		replaceFile(t, lockfile)
+		require.ErrorIs(t, l.Verify(), fcntllock.ErrLockFileReplaced)
+		err := l.UnLock()
+		require.ErrorIs(t, err, fcntllock.ErrLockFileReplaced)
+		require.Contains(t, err.Error(), lockfile)
+		require.False(t, l.HeldByMe(), "the replaced lock file is released")
+	})
+
+	t.Run("removed lock file is detected", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithInodeCheck(true)).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		require.NoError(t, os.Remove(lockfile))
+		require.ErrorIs(t, l.Verify(), fcntllock.ErrLockFileReplaced)
+		require.ErrorIs(t, l.UnLock(), fcntllock.ErrLockFileReplaced)
+	})
+}
+
+func TestVerify(t *testing.T) {
+	t.Run("not held", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.ErrorIs(t, fcntllock.New(lockfile).(*fcntllock.Lock).Verify(), fcntllock.ErrNotLocked)
+	})
+
+	t.Run("without inode check the lock file descriptor is compared", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.Verify())
+		replaceFile(t, lockfile)
+		require.ErrorIs(t, l.Verify(), fcntllock.ErrLockFileReplaced)
+		require.NoError(t, l.UnLock(), "UnLock only checks with WithInodeCheck")
+	})
+}
@@ -0,0 +1,52 @@
+package fcntllock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLock(t *testing.T) {
+	t.Run("waits for another process to release the lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// start in fork a lock and holds it during 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		t1 := time.Now()
+		require.NoError(t, l.Lock())
+		require.Greater(t, int64(time.Since(t1)), int64(20*time.Millisecond))
+		require.NoError(t, forkCmd.Wait())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("self relock returns ErrWouldSelfDeadlock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.Lock())
+		require.ErrorIs(t, l.Lock(), fcntllock.ErrWouldSelfDeadlock)
+		require.True(t, l.HeldByMe())
+		require.NoError(t, l.UnLock())
+		require.NoError(t, l.Lock())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("self relock succeeds when reentrant", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithReentrant(true)).(*fcntllock.Lock)
+		require.NoError(t, l.Lock())
+		require.NoError(t, l.Lock())
+		require.True(t, l.HeldByMe())
+		require.NoError(t, l.UnLock())
+	})
+}
@@ -0,0 +1,100 @@
+package fcntllock_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLockAll(t *testing.T) {
+	t.Run("all locks are acquired then released", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		paths := []string{filepath.Join(lockDir, "b"), filepath.Join(lockDir, "a"), filepath.Join(lockDir, "c")}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		unlock, err := fcntllock.LockAll(ctx, 10*time.Millisecond, paths...)
+		require.NoError(t, err)
+
+		for _, p := range paths {
+			forkCmd := lockInFork("TryLock", p)
+			require.NoError(t, forkCmd.Start())
+			require.Error(t, forkCmd.Wait(), "expected %s to be held", p)
+		}
+
+		require.NoError(t, unlock())
+		for _, p := range paths {
+			forkCmd := lockInFork("TryLock", p)
+			require.NoError(t, forkCmd.Start())
+			require.NoError(t, forkCmd.Wait(), "expected %s to be released", p)
+		}
+	})
+
+	t.Run("paths resolving to the same file are locked once", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		a := filepath.Join(lockDir, "a")
+		link := filepath.Join(lockDir, "link")
+		require.NoError(t, ioutil.WriteFile(a, nil, 0600))
+		require.NoError(t, os.Symlink(a, link))
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		opened := fcntllock.OpenFDs()
+		unlock, err := fcntllock.LockAll(ctx, 10*time.Millisecond, link, filepath.Join(lockDir, "x", "..", "a"), a)
+		require.NoError(t, err)
+		require.Equal(t, opened+1, fcntllock.OpenFDs(), "expected a single lock file open")
+
+		forkCmd := lockInFork("TryLock", a)
+		require.NoError(t, forkCmd.Start())
+		require.Error(t, forkCmd.Wait(), "expected %s to be held", a)
+
+		require.NoError(t, unlock())
+		forkCmd = lockInFork("TryLock", a)
+		require.NoError(t, forkCmd.Start())
+		require.NoError(t, forkCmd.Wait(), "expected %s to be released", a)
+	})
+
+	t.Run("partial failure releases already acquired locks", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		tf, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		okPath := filepath.Join(lockDir, "a")
+		badPath := filepath.Join(tf, "dir", "z")
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		unlock, err := fcntllock.LockAll(ctx, 10*time.Millisecond, badPath, okPath)
+		require.Error(t, err)
+		require.Nil(t, unlock)
+
+		forkCmd := lockInFork("TryLock", okPath)
+		require.NoError(t, forkCmd.Start())
+		require.NoError(t, forkCmd.Wait(), "expected %s to be released", okPath)
+	})
+
+	t.Run("two processes locking the same set in different orders don't deadlock", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		a := filepath.Join(lockDir, "a")
+		b := filepath.Join(lockDir, "b")
+
+		forkCmd := lockInFork("LockAll", b, a)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(20 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		unlock, err := fcntllock.LockAll(ctx, 10*time.Millisecond, a, b)
+		require.NoError(t, err)
+		require.NoError(t, unlock())
+		require.NoError(t, forkCmd.Wait())
+	})
+}
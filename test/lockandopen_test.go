@@ -0,0 +1,54 @@
+package fcntllock_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLockAndOpen(t *testing.T) {
+	t.Run("read and write the content through the returned file", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, []byte("counter=1\n"), 0600))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		f, err := l.LockAndOpen(context.Background(), 10*time.Millisecond)
+		require.NoError(t, err)
+		require.True(t, l.HeldByMe())
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		require.Equal(t, "counter=1\n", string(b))
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+		_, err = f.Write([]byte("counter=2\n"))
+		require.NoError(t, err)
+		require.NoError(t, l.UnLock())
+
+		b, err = ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "counter=2\n", string(b))
+	})
+
+	t.Run("lock held without lock file", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		path := filepath.Join(lockDir, "lck")
+		l := fcntllock.New(path, fcntllock.WithBackend(newMemBackend())).(*fcntllock.Lock)
+		f, err := l.LockAndOpen(context.Background(), 10*time.Millisecond)
+		require.ErrorIs(t, err, fcntllock.ErrNoFd)
+		require.Nil(t, f)
+		require.False(t, l.HeldByMe())
+	})
+}
@@ -0,0 +1,109 @@
+package fcntllock_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+// rangeLockable returns true if another process can lock length bytes at
+// start of path
+func rangeLockable(t *testing.T, path string, start, length int64) bool {
+	t.Helper()
+	forkCmd := lockInFork("TryLockRange", path, strconv.FormatInt(start, 10), strconv.FormatInt(length, 10))
+	require.NoError(t, forkCmd.Start())
+	return forkCmd.Wait() == nil
+}
+
+func TestLockFrom(t *testing.T) {
+	t.Run("locks from the current offset", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, make([]byte, 100), 0600))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.LockFrom(0))
+		require.NoError(t, l.UnLock())
+
+		_, err := l.Seek(50, io.SeekStart)
+		require.NoError(t, err)
+		require.NoError(t, l.LockFrom(10))
+		offset, err := l.Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		require.Equal(t, int64(50), offset, "LockFrom must not move the offset")
+
+		require.True(t, rangeLockable(t, lockfile, 0, 50))
+		require.False(t, rangeLockable(t, lockfile, 50, 10))
+		require.False(t, rangeLockable(t, lockfile, 59, 1))
+		require.True(t, rangeLockable(t, lockfile, 60, 40))
+
+		require.NoError(t, l.UnLock())
+		require.True(t, rangeLockable(t, lockfile, 50, 10))
+	})
+
+	t.Run("zero length locks up to the end of file", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.LockFrom(0))
+		require.NoError(t, l.UnLock())
+		_, err := l.Seek(20, io.SeekStart)
+		require.NoError(t, err)
+		require.NoError(t, l.LockFrom(0))
+		require.True(t, rangeLockable(t, lockfile, 0, 20))
+		require.False(t, rangeLockable(t, lockfile, 1000, 1))
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("sets the effective mode", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithOFD(true)).(*fcntllock.Lock)
+		require.NoError(t, l.LockFrom(0))
+		require.Equal(t, fcntllock.ModeOFD, l.EffectiveMode())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("invalid identity is rejected like TryLock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithIdentity("tenant\n42")).(*fcntllock.Lock)
+		require.ErrorIs(t, l.LockFrom(0), fcntllock.ErrInvalidIdentity)
+		require.False(t, l.HeldByMe())
+	})
+
+	t.Run("done base context is rejected like TryLock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		l := fcntllock.New(lockfile, fcntllock.WithContext(ctx)).(*fcntllock.Lock)
+		require.ErrorIs(t, l.LockFrom(0), context.Canceled)
+		require.False(t, l.HeldByMe())
+		require.True(t, rangeLockable(t, lockfile, 0, 0))
+	})
+
+	t.Run("busy range keeps the file offset", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, make([]byte, 100), 0600))
+		forkCmd := startLockInFork(t, "HoldRange", lockfile, "10", "10")
+		defer func() { _ = forkCmd.Wait() }()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.LockFrom(1))
+		require.NoError(t, l.UnLock())
+		_, err := l.Seek(15, io.SeekStart)
+		require.NoError(t, err)
+		require.Error(t, l.LockFrom(1))
+		offset, err := l.Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		require.Equal(t, int64(15), offset)
+		require.NoError(t, l.Close())
+	})
+}
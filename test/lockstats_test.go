@@ -0,0 +1,65 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLockContextStats(t *testing.T) {
+	t.Run("free lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		waited, attempts, err := l.LockContextStats(context.Background(), 10*time.Millisecond)
+		require.NoError(t, err)
+		require.Equal(t, 1, attempts)
+		require.Less(t, int64(waited), int64(10*time.Millisecond))
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("success after contention", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// start in fork a lock and holds it during 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		waited, attempts, err := l.LockContextStats(ctx, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.NoError(t, forkCmd.Wait())
+		require.GreaterOrEqual(t, int64(waited), int64(30*time.Millisecond))
+		require.Less(t, int64(waited), int64(500*time.Millisecond))
+		// one attempt per retry delay during the wait, plus the success
+		require.GreaterOrEqual(t, attempts, 3)
+		require.LessOrEqual(t, attempts, int(waited/(10*time.Millisecond))+1)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(20 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 40*time.Millisecond)
+		defer cancel()
+		waited, attempts, err := l.LockContextStats(ctx, 10*time.Millisecond)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.GreaterOrEqual(t, int64(waited), int64(40*time.Millisecond))
+		require.GreaterOrEqual(t, attempts, 2)
+		require.NoError(t, forkCmd.Wait())
+	})
+}
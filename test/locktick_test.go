@@ -0,0 +1,73 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLockContextTick(t *testing.T) {
+	t.Run("no tick on a free lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		var ticks int
+		require.NoError(t, l.LockContextTick(context.Background(), 10*time.Millisecond, func() { ticks++ }))
+		require.Zero(t, ticks)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("one tick per retry during a contended wait", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// start in fork a lock and holds it during 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		var ticks int
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := l.LockContextTick(ctx, 10*time.Millisecond, func() {
+			require.False(t, l.HeldByMe(), "tick after acquisition")
+			ticks++
+		})
+		require.NoError(t, err)
+		require.NoError(t, forkCmd.Wait())
+		// the fork holds the lock ~50ms more, with a 10ms retry delay
+		require.GreaterOrEqual(t, ticks, 2)
+		require.LessOrEqual(t, ticks, 12)
+		acquiredTicks := ticks
+		require.NoError(t, l.UnLock())
+		time.Sleep(30 * time.Millisecond)
+		require.Equal(t, acquiredTicks, ticks, "no tick after acquisition")
+	})
+
+	t.Run("no tick after the context end", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		defer func() { _ = forkCmd.Wait() }()
+		time.Sleep(50 * time.Millisecond)
+
+		var ticks int
+		ctx, cancel := context.WithTimeout(context.Background(), 35*time.Millisecond)
+		defer cancel()
+		err := l.LockContextTick(ctx, 10*time.Millisecond, func() { ticks++ })
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		endTicks := ticks
+		require.GreaterOrEqual(t, endTicks, 2)
+		require.LessOrEqual(t, endTicks, 4)
+		time.Sleep(30 * time.Millisecond)
+		require.Equal(t, endTicks, ticks)
+	})
+}
@@ -0,0 +1,161 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestAcquireBest(t *testing.T) {
+	t.Run("preferred exclusive lock on free lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		got, err := l.AcquireBest(context.Background(), fcntllock.Exclusive, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.Equal(t, fcntllock.Exclusive, got)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("exclusive blocked by shared holder falls back to shared", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// start in fork a shared lock and holds it during 102 milliseconds
+		forkCmd := lockInFork("TryRLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		got, err := l.AcquireBest(ctx, fcntllock.Exclusive, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.Equal(t, fcntllock.Shared, got)
+		require.NoError(t, forkCmd.Wait())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("no fallback for an exclusive holder", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := l.AcquireBest(ctx, fcntllock.Exclusive, 10*time.Millisecond)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("preferred shared lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		got, err := l.AcquireBest(context.Background(), fcntllock.Shared, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.Equal(t, fcntllock.Shared, got)
+		require.NoError(t, l.UnLock())
+	})
+}
+
+func TestLockType(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	l := fcntllock.New(lockfile).(*fcntllock.Lock)
+	_, held := l.LockType()
+	require.False(t, held)
+
+	require.NoError(t, l.TryLock())
+	typ, held := l.LockType()
+	require.True(t, held)
+	require.Equal(t, fcntllock.Exclusive, typ)
+	require.NoError(t, l.UnLock())
+	_, held = l.LockType()
+	require.False(t, held)
+
+	require.NoError(t, l.TryRLock())
+	typ, held = l.LockType()
+	require.True(t, held)
+	require.Equal(t, fcntllock.Shared, typ)
+	require.NoError(t, l.UnLock())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, l.LockWait(ctx))
+	typ, held = l.LockType()
+	require.True(t, held)
+	require.Equal(t, fcntllock.Exclusive, typ)
+	require.NoError(t, l.UnLock())
+	_, held = l.LockType()
+	require.False(t, held)
+}
+
+func TestUpgrade(t *testing.T) {
+	t.Run("not held", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.ErrorIs(t, l.Upgrade(context.Background(), 10*time.Millisecond), fcntllock.ErrNotLocked)
+	})
+
+	t.Run("upgraded after the other reader releases", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// start in fork a shared lock and holds it during 102 milliseconds
+		forkCmd := lockInFork("TryRLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, l.TryRLock())
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, l.Upgrade(ctx, 10*time.Millisecond))
+		typ, held := l.LockType()
+		require.True(t, held)
+		require.Equal(t, fcntllock.Exclusive, typ)
+		require.NoError(t, forkCmd.Wait())
+
+		// the upgraded lock conflicts with the other readers
+		forkCmd = lockInFork("TryRLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		require.Error(t, forkCmd.Wait())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("context done keeps the shared lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		forkCmd := lockInFork("TryRLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		defer func() { _ = forkCmd.Wait() }()
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, l.TryRLock())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, l.Upgrade(ctx, 5*time.Millisecond), context.DeadlineExceeded)
+		typ, held := l.LockType()
+		require.True(t, held)
+		require.Equal(t, fcntllock.Shared, typ)
+
+		// no writer can get the lock meanwhile
+		writer := lockInFork("TryLock", lockfile)
+		require.NoError(t, writer.Start())
+		require.Error(t, writer.Wait())
+		require.NoError(t, l.UnLock())
+	})
+}
@@ -0,0 +1,58 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLockWhile(t *testing.T) {
+	t.Run("condition true acquires the free lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.LockWhile(context.Background(), 10*time.Millisecond, func() bool { return true }))
+		require.True(t, l.HeldByMe())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("condition false before the first attempt", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		err := l.LockWhile(context.Background(), 10*time.Millisecond, func() bool { return false })
+		require.ErrorIs(t, err, fcntllock.ErrConditionFalse)
+		require.False(t, l.HeldByMe())
+	})
+
+	t.Run("condition flips to false mid-loop", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// start in fork a lock and holds it during 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		defer func() { _ = forkCmd.Wait() }()
+		time.Sleep(50 * time.Millisecond)
+
+		var calls int
+		cond := func() bool {
+			calls++
+			return calls <= 3
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		begin := time.Now()
+		err := l.LockWhile(ctx, 10*time.Millisecond, cond)
+		require.ErrorIs(t, err, fcntllock.ErrConditionFalse)
+		require.Equal(t, 4, calls)
+		require.Less(t, int64(time.Since(begin)), int64(500*time.Millisecond))
+		require.False(t, l.HeldByMe())
+	})
+}
@@ -0,0 +1,37 @@
+package fcntllock_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLockContextID(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	var buf bytes.Buffer
+	l := fcntllock.New(lockfile, fcntllock.WithLogger(log.New(&buf, "", 0))).(*fcntllock.Lock)
+
+	forkCmd := lockInFork("TryLock", lockfile)
+	require.NoError(t, forkCmd.Start())
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, l.LockContextID(ctx, 25*time.Millisecond, "req-42"))
+	require.NoError(t, forkCmd.Wait())
+	require.Contains(t, buf.String(), "fcntllock "+lockfile+" id=req-42: busy, retry in 25ms\n")
+	require.Contains(t, buf.String(), "fcntllock "+lockfile+" id=req-42: acquired\n")
+
+	buf.Reset()
+	require.NoError(t, l.UnLock())
+	require.Equal(t, "fcntllock "+lockfile+": released\n", buf.String(),
+		"correlation id must not outlive the acquisition")
+}
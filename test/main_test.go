@@ -2,11 +2,14 @@ package fcntllock_test
 
 import (
 	"context"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"runtime"
-	"strings"
+	"strconv"
+	"syscall"
 	"testing"
 	"time"
 
@@ -53,24 +56,6 @@ func TestLockContext(t *testing.T) {
 		require.Less(t, t2.Sub(t1), 3*time.Millisecond)
 	})
 
-	for _, p := range []string{"/root/foo", "/var/.no-such-fcntllock-file"} {
-		t.Run("fail fast on perm error and verify duration for file "+p, func(t *testing.T) {
-			l := fcntllock.New(p)
-			t1 := time.Now()
-			ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
-			defer cancel()
-			err := l.LockContext(ctx, 25*time.Millisecond)
-			require.Error(t, l.LockContext(ctx, 25*time.Millisecond))
-			if runtime.GOOS == "darwin" && strings.HasPrefix(p, "/root/") {
-				require.Contains(t, err.Error(), "read-only file system")
-			} else {
-				require.ErrorIs(t, err, os.ErrPermission)
-			}
-			t2 := time.Now()
-			require.Less(t, t2.Sub(t1), 30*time.Millisecond)
-		})
-	}
-
 	t.Run("LockContext give at least one try lock even if context is already Done", func(t *testing.T) {
 		lockDir, cleanup := testhelper.Tempdir(t)
 		defer cleanup()
@@ -119,6 +104,55 @@ func TestLockContext(t *testing.T) {
 	})
 }
 
+func TestLockBounded(t *testing.T) {
+	t.Run("hardMax fires before the context", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// start in fork a lock and holds it during 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		t1 := time.Now()
+		err := l.LockBounded(context.Background(), 5*time.Millisecond, 20*time.Millisecond)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, int64(time.Since(t1)), int64(40*time.Millisecond))
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("context fires before hardMax", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// start in fork a lock and holds it during 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+		}()
+		t1 := time.Now()
+		err := l.LockBounded(ctx, 5*time.Millisecond, time.Second)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Less(t, int64(time.Since(t1)), int64(40*time.Millisecond))
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("succeed within hardMax", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.LockBounded(context.Background(), 5*time.Millisecond, 20*time.Millisecond))
+		require.NoError(t, l.UnLock())
+	})
+}
+
 func TestTryLock(t *testing.T) {
 	t.Run("lockfile is created", func(t *testing.T) {
 		lockfile, tfCleanup := testhelper.TempFile(t)
@@ -192,14 +226,83 @@ func TestUnLock(t *testing.T) {
 	})
 }
 
+func TestUnLockResult(t *testing.T) {
+	t.Run("released is true when the lock was held", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+
+		released, err := l.UnLockResult()
+		require.NoError(t, err)
+		require.True(t, released)
+
+		released, err = l.UnLockResult()
+		require.NoError(t, err)
+		require.False(t, released, "double unlock must report no release")
+	})
+
+	t.Run("released is false when never locked", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		released, err := l.UnLockResult()
+		require.NoError(t, err)
+		require.False(t, released)
+	})
+}
+
 func lockInFork(command string, args ...string) *exec.Cmd {
 	cs := []string{"-test.run=TestHelperProcess", "--", command}
 	cs = append(cs, args...)
 	cmd := exec.Command(os.Args[0], cs...)
-	cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
+	// the race detector delays the exit, so the lock release, by 1s
+	cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1", "GORACE=atexit_sleep_ms=0"}
+	return cmd
+}
+
+// startLockInFork starts the helper command, and returns once the helper
+// process holds the lock: the process writes to the ready pipe, its fd 3,
+// once the lock is acquired. The test fails if the process exits without
+// holding the lock.
+func startLockInFork(t *testing.T, command string, args ...string) *exec.Cmd {
+	t.Helper()
+	return startLockInForkWith(t, nil, command, args...)
+}
+
+// startLockInForkWith is startLockInFork passing files to the helper
+// process, as its fds from 3, the ready pipe being the next fd.
+func startLockInForkWith(t *testing.T, files []*os.File, command string, args ...string) *exec.Cmd {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+	cmd := lockInFork(command, args...)
+	cmd.Env = append(cmd.Env, "HELPER_READY_FD="+strconv.Itoa(3+len(files)))
+	cmd.ExtraFiles = append(files, w)
+	err = cmd.Start()
+	_ = w.Close()
+	require.NoError(t, err)
+	if _, err := r.Read(make([]byte, 1)); err != nil {
+		_ = cmd.Wait()
+		t.Fatalf("%s helper process not ready: %s", command, err)
+	}
 	return cmd
 }
 
+// helperReady tells the startLockInFork caller the helper process holds the
+// lock, writing to the HELPER_READY_FD descriptor
+func helperReady() {
+	fd, err := strconv.Atoi(os.Getenv("HELPER_READY_FD"))
+	if err != nil {
+		return
+	}
+	ready := os.NewFile(uintptr(fd), "ready")
+	_, _ = ready.Write([]byte{0})
+	_ = ready.Close()
+}
+
 func TestHelperProcess(t *testing.T) {
 	t.Helper()
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
@@ -226,17 +329,178 @@ func TestHelperProcess(t *testing.T) {
 		if err != nil {
 			exitCode = 1
 		} else {
+			helperReady()
 			time.Sleep(102 * time.Millisecond)
 			return
 		}
+	case cmd == "TryRLock":
+		err := lock.(*fcntllock.Lock).TryRLock()
+		if err != nil {
+			exitCode = 1
+		} else {
+			helperReady()
+			time.Sleep(102 * time.Millisecond)
+			return
+		}
+	case cmd == "TryLockRange":
+		// exit 0 if the range args[2]:args[3] can be locked
+		start, _ := strconv.ParseInt(args[2], 10, 64)
+		length, _ := strconv.ParseInt(args[3], 10, 64)
+		file, err := os.OpenFile(name, os.O_RDWR, 0)
+		if err != nil {
+			exitCode = 1
+			break
+		}
+		ft := &syscall.Flock_t{Start: start, Len: length, Type: syscall.F_WRLCK, Whence: io.SeekStart}
+		if err := syscall.FcntlFlock(file.Fd(), syscall.F_SETLK, ft); err != nil {
+			exitCode = 1
+		}
+	case cmd == "HoldRange":
+		// hold the range args[2]:args[3] lock during 102 milliseconds
+		start, _ := strconv.ParseInt(args[2], 10, 64)
+		length, _ := strconv.ParseInt(args[3], 10, 64)
+		file, err := os.OpenFile(name, os.O_RDWR, 0)
+		if err != nil {
+			exitCode = 1
+			break
+		}
+		ft := &syscall.Flock_t{Start: start, Len: length, Type: syscall.F_WRLCK, Whence: io.SeekStart}
+		if err := syscall.FcntlFlock(file.Fd(), syscall.F_SETLK, ft); err != nil {
+			exitCode = 1
+			break
+		}
+		helperReady()
+		time.Sleep(102 * time.Millisecond)
 	case cmd == "LockContext":
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 		defer cancel()
 		err := lock.LockContext(ctx, 102*time.Millisecond)
+		if err != nil {
+			exitCode = 1
+		} else {
+			helperReady()
+			time.Sleep(102 * time.Millisecond)
+		}
+	case cmd == "LockContextCounted":
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		lock = fcntllock.New(name, fcntllock.WithContentionCounter())
+		err := lock.LockContext(ctx, 10*time.Millisecond)
+		if err != nil {
+			exitCode = 1
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+	case cmd == "LockContextFIFO":
+		// args[2] is the skipAfter duration, args[3] the file to append
+		// args[4] to once the lock is acquired
+		skipAfter, _ := time.ParseDuration(args[2])
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		lock = fcntllock.New(name, fcntllock.WithFIFO(skipAfter))
+		err := lock.LockContext(ctx, 10*time.Millisecond)
+		if err != nil {
+			exitCode = 1
+			break
+		}
+		f, err := os.OpenFile(args[3], os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err == nil {
+			_, err = f.WriteString(args[4] + "\n")
+			_ = f.Close()
+		}
+		if err != nil {
+			exitCode = 1
+		}
+		time.Sleep(20 * time.Millisecond)
+	case cmd == "IntentLock", cmd == "CommitLock":
+		// args[2] is the child region
+		child, _ := strconv.ParseInt(args[2], 10, 64)
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		l := fcntllock.NewTwoPhaseLock(name, child, 10*time.Millisecond)
+		err := l.IntentLock(ctx)
+		if err == nil && cmd == "CommitLock" {
+			err = l.CommitLock(ctx)
+		}
+		if err != nil {
+			exitCode = 1
+		} else {
+			time.Sleep(102 * time.Millisecond)
+			_ = l.Release()
+		}
+	case cmd == "OrphanParent":
+		// start an OrphanChild, and exit once it holds the lock, args[2]
+		// is the file created by the child once the lock is held
+		child := lockInFork("OrphanChild", name, args[2])
+		if err := child.Start(); err != nil {
+			exitCode = 1
+			break
+		}
+		exitCode = 1
+		for i := 0; i < 300; i++ {
+			if _, err := os.Stat(args[2]); err == nil {
+				exitCode = 0
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	case cmd == "OrphanChild":
+		lock = fcntllock.New(name, fcntllock.WithReleaseOnOrphan(true))
+		if err := lock.TryLock(); err != nil {
+			exitCode = 1
+			break
+		}
+		if err := ioutil.WriteFile(args[2], nil, 0600); err != nil {
+			exitCode = 1
+			break
+		}
+		time.Sleep(3 * time.Second)
+	case cmd == "SingleInstance":
+		// name is the program name, the lock dir is $XDG_RUNTIME_DIR
+		if _, err := fcntllock.SingleInstance(name); err != nil {
+			exitCode = 1
+		} else {
+			time.Sleep(102 * time.Millisecond)
+		}
+	case cmd == "TryLockUntilSignal":
+		// hold the lock until SIGUSR1, exit 1 if not received within 2
+		// seconds
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGUSR1)
+		if err := lock.TryLock(); err != nil {
+			exitCode = 1
+			break
+		}
+		helperReady()
+		select {
+		case <-sigs:
+		case <-time.After(2 * time.Second):
+			exitCode = 1
+		}
+	case cmd == "HoldRawFd":
+		// the lock file is the fd 3, held with the args[2] lock kind until
+		// SIGUSR1, exit 1 if not received within 2 seconds
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGUSR1)
+		l := fcntllock.FromRawFd(3, name, true, fcntllock.WithOFD(args[2] == "ofd"))
+		helperReady()
+		select {
+		case <-sigs:
+			if err := l.UnLock(); err != nil {
+				exitCode = 1
+			}
+		case <-time.After(2 * time.Second):
+			exitCode = 1
+		}
+	case cmd == "LockAll":
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		unlock, err := fcntllock.LockAll(ctx, 10*time.Millisecond, args[1:]...)
 		if err != nil {
 			exitCode = 1
 		} else {
 			time.Sleep(102 * time.Millisecond)
+			_ = unlock()
 		}
 	default:
 		exitCode = 1
@@ -0,0 +1,40 @@
+package fcntllock_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestIsMandatory(t *testing.T) {
+	t.Run("regular lock file is advisory", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		mandatory, err := l.IsMandatory()
+		require.NoError(t, err)
+		require.False(t, mandatory)
+	})
+
+	t.Run("setgid without group execute is not enough without mand mount", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, os.Chmod(lockfile, 0640|os.ModeSetgid))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		mandatory, err := l.IsMandatory()
+		require.NoError(t, err)
+		require.False(t, mandatory)
+	})
+
+	t.Run("missing lock file", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		l := fcntllock.New(lockDir + "/lck").(*fcntllock.Lock)
+		_, err := l.IsMandatory()
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,329 @@
+package fcntllock_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithMetadata(t *testing.T) {
+	t.Run("metadata is written on acquisition", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithMetadata())
+		t1 := time.Now()
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		host, _ := os.Hostname()
+		require.Equal(t, os.Getpid(), m.PID)
+		require.Equal(t, host, m.Host)
+		require.False(t, m.Acquired.Before(t1.Truncate(time.Second)))
+		require.Zero(t, m.Lease)
+	})
+
+	t.Run("metadata is written after the template", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "lck")
+		template := "# managed by myapp, do not edit\n"
+		l := fcntllock.New(lockfile, fcntllock.WithMetadata(), fcntllock.WithCreateTemplate([]byte(template)))
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.UnLock())
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(string(b), template+"pid="), "unexpected content %q", b)
+		require.Equal(t, 1, strings.Count(string(b), "pid="))
+		m, err := fcntllock.ParseMetadata(b)
+		require.NoError(t, err)
+		require.Equal(t, os.Getpid(), m.PID)
+	})
+
+	t.Run("invalid metadata", func(t *testing.T) {
+		_, err := fcntllock.ParseMetadata([]byte("pid=abc\n"))
+		require.Error(t, err)
+	})
+}
+
+func TestReadMetadataFrom(t *testing.T) {
+	m := &fcntllock.Metadata{
+		PID:      1234,
+		Host:     "node1",
+		Acquired: time.Date(2021, 4, 23, 8, 28, 22, 0, time.UTC),
+		Reason:   "nightly backup",
+	}
+	content := append([]byte("# lock file template\n"), m.Bytes()...)
+
+	// a slow storage reader returning one byte per read
+	got, err := fcntllock.ReadMetadataFrom(iotest.OneByteReader(bytes.NewReader(content)))
+	require.NoError(t, err)
+	require.Equal(t, m.PID, got.PID)
+	require.Equal(t, m.Host, got.Host)
+	require.True(t, m.Acquired.Equal(got.Acquired))
+	require.Equal(t, m.Reason, got.Reason)
+
+	_, err = fcntllock.ReadMetadataFrom(iotest.TimeoutReader(iotest.OneByteReader(bytes.NewReader(content))))
+	require.ErrorIs(t, err, iotest.ErrTimeout)
+}
+
+func TestWithLease(t *testing.T) {
+	t.Run("renewal advances the timestamp", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithLease(60*time.Millisecond)).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+
+		m1, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, 60*time.Millisecond, m1.Lease)
+		time.Sleep(70 * time.Millisecond)
+		m2, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.True(t, m2.Renewed.After(m1.Renewed))
+		require.Equal(t, m1.Acquired, m2.Acquired)
+
+		observer := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.False(t, observer.LeaseExpired())
+	})
+
+	t.Run("stopped renewer lets the lease expire", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithLease(30*time.Millisecond)).(*fcntllock.Lock)
+		observer := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		require.False(t, observer.LeaseExpired())
+
+		require.NoError(t, l.UnLock())
+		time.Sleep(40 * time.Millisecond)
+		require.True(t, observer.LeaseExpired())
+	})
+
+	t.Run("holder checking its own lease keeps the lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithLease(time.Minute)).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		require.False(t, l.LeaseExpired())
+
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		require.Error(t, forkCmd.Wait(), "expected the lock to be still held")
+	})
+
+	t.Run("zero or tiny ttl", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		for _, ttl := range []time.Duration{-time.Second, 0, 1, 2} {
+			l := fcntllock.New(lockfile, fcntllock.WithLease(ttl)).(*fcntllock.Lock)
+			require.NoError(t, l.TryLock())
+			require.NoError(t, l.UnLock())
+		}
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, time.Duration(2), m.Lease)
+	})
+
+	t.Run("no lease never expires", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithMetadata()).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.UnLock())
+		require.False(t, l.LeaseExpired())
+	})
+}
+
+func TestRepairMetadata(t *testing.T) {
+	for name, corrupted := range map[string]string{
+		"empty":     "",
+		"truncated": "pid=12",
+		"malformed": "pid=12x\nhost=h\nacquired=2020-01-01T00:00:00Z\n",
+		"garbage":   "\x00\x00\x00",
+	} {
+		corrupted := corrupted
+		t.Run(name, func(t *testing.T) {
+			lockfile, tfCleanup := testhelper.TempFile(t)
+			defer tfCleanup()
+			l := fcntllock.New(lockfile, fcntllock.WithMetadata()).(*fcntllock.Lock)
+			require.NoError(t, l.TryLock())
+			defer func() { _ = l.UnLock() }()
+			require.NoError(t, ioutil.WriteFile(lockfile, []byte(corrupted), 0600))
+
+			require.NoError(t, l.RepairMetadata())
+			m, err := fcntllock.ReadMetadata(lockfile)
+			require.NoError(t, err)
+			require.Equal(t, os.Getpid(), m.PID)
+			require.NotEmpty(t, m.Host)
+			require.False(t, m.Acquired.IsZero())
+		})
+	}
+
+	t.Run("valid metadata is kept", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithMetadata()).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		valid := "pid=1\nhost=other\nacquired=2020-01-01T00:00:00Z\n"
+		require.NoError(t, ioutil.WriteFile(lockfile, []byte(valid), 0600))
+
+		require.NoError(t, l.RepairMetadata())
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, valid, string(b))
+	})
+
+	t.Run("not held", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithMetadata()).(*fcntllock.Lock)
+		require.ErrorIs(t, l.RepairMetadata(), fcntllock.ErrNotLocked)
+	})
+}
+
+func TestLockReason(t *testing.T) {
+	t.Run("reason is written with the metadata", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.LockReason(context.Background(), 10*time.Millisecond, "running backup"))
+		defer func() { _ = l.UnLock() }()
+
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "running backup", m.Reason)
+		require.Equal(t, os.Getpid(), m.PID)
+	})
+
+	t.Run("reason newlines are replaced", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithMetadata()).(*fcntllock.Lock)
+		require.NoError(t, l.LockReason(context.Background(), 10*time.Millisecond, "db\nmigration"))
+		defer func() { _ = l.UnLock() }()
+
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "db migration", m.Reason)
+	})
+
+	t.Run("reason is forgotten on UnLock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithMetadata()).(*fcntllock.Lock)
+		require.NoError(t, l.LockReason(context.Background(), 10*time.Millisecond, "running backup"))
+		require.NoError(t, l.UnLock())
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Empty(t, m.Reason)
+	})
+}
+
+func TestWithIdentity(t *testing.T) {
+	t.Run("identity is written with the metadata", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithIdentity("tenant-42/billing")).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "tenant-42/billing", m.Identity)
+		require.Equal(t, os.Getpid(), m.PID)
+	})
+
+	t.Run("identity is written in the atomically created lock file", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "lck")
+		l := fcntllock.New(lockfile, fcntllock.WithAtomicCreate(true), fcntllock.WithIdentity("tenant-a")).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "tenant-a", m.Identity)
+	})
+
+	t.Run("identity round trips through the metadata", func(t *testing.T) {
+		m := &fcntllock.Metadata{PID: 1, Host: "node1", Acquired: time.Now(), Identity: "svc=api tenant=ünïcode"}
+		got, err := fcntllock.ParseMetadata(m.Bytes())
+		require.NoError(t, err)
+		require.Equal(t, m.Identity, got.Identity)
+	})
+
+	for _, id := range []string{"tenant\n42", "tenant\r42", "tenant\x0042", "tenant\x1b[0m"} {
+		id := id
+		t.Run("control characters are rejected "+strconv.Quote(id), func(t *testing.T) {
+			lockfile, tfCleanup := testhelper.TempFile(t)
+			defer tfCleanup()
+			l := fcntllock.New(lockfile, fcntllock.WithIdentity(id)).(*fcntllock.Lock)
+			require.ErrorIs(t, l.TryLock(), fcntllock.ErrInvalidIdentity)
+			require.ErrorIs(t, l.LockWait(context.Background()), fcntllock.ErrInvalidIdentity)
+			require.False(t, l.HeldByMe())
+		})
+	}
+}
+
+func TestWithRecordWait(t *testing.T) {
+	t.Run("contended acquisition wait is recorded", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithRecordWait()).(*fcntllock.Lock)
+
+		// the forked lock process holds the lock 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		waited, _, err := l.LockContextStats(ctx, 5*time.Millisecond)
+		require.NoError(t, err)
+		require.NoError(t, forkCmd.Wait())
+		defer func() { _ = l.UnLock() }()
+
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, int64(m.Waited), int64(20*time.Millisecond))
+		require.LessOrEqual(t, int64(m.Waited), int64(waited))
+		require.Equal(t, os.Getpid(), m.PID)
+	})
+
+	t.Run("acquisition without wait records none", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithRecordWait()).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Zero(t, m.Waited)
+		require.NotContains(t, string(m.Bytes()), "waited=")
+	})
+}
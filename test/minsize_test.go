@@ -0,0 +1,91 @@
+package fcntllock_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithMinSize(t *testing.T) {
+	size := func(t *testing.T, path string) int64 {
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		return fi.Size()
+	}
+
+	t.Run("created file is grown to the minimum size", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "lck")
+		l := fcntllock.New(lockfile, fcntllock.WithMinSize(4096))
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		require.Equal(t, int64(4096), size(t, lockfile))
+	})
+
+	t.Run("larger file is left unchanged", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		content := bytes.Repeat([]byte("x"), 100)
+		require.NoError(t, ioutil.WriteFile(lockfile, content, 0600))
+		l := fcntllock.New(lockfile, fcntllock.WithMinSize(10))
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, content, b)
+	})
+
+	t.Run("template and metadata keep the minimum size", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "lck")
+		template := []byte("# managed by myapp, do not edit\n")
+		l := fcntllock.New(lockfile,
+			fcntllock.WithMinSize(1<<20),
+			fcntllock.WithCreateTemplate(template),
+			fcntllock.WithMetadata())
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		require.Equal(t, int64(1<<20), size(t, lockfile))
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(b, template))
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, os.Getpid(), m.PID)
+	})
+
+	t.Run("content writes keep the minimum size", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithMinSize(4096)).(*fcntllock.Lock)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, l.Update(ctx, 10*time.Millisecond, func([]byte) ([]byte, error) {
+			return []byte("updated"), nil
+		}))
+		require.Equal(t, int64(4096), size(t, lockfile))
+
+		current, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.NoError(t, l.TryLock())
+		swapped, err := l.CompareAndSwap(current, []byte("swapped"))
+		require.NoError(t, err)
+		require.True(t, swapped)
+		require.NoError(t, l.UnLock())
+		require.Equal(t, int64(4096), size(t, lockfile))
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(b, []byte("swapped\x00")))
+	})
+}
@@ -0,0 +1,28 @@
+//go:build go1.18
+// +build go1.18
+
+package fcntllock_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func FuzzSanitizeName(f *testing.F) {
+	for _, seed := range []string{"lck", "..", "../lck", "a/b", "lck\x00", "", "."} {
+		f.Add(seed)
+	}
+	base := "/run/app"
+	f.Fuzz(func(t *testing.T, name string) {
+		s, err := fcntllock.SanitizeName(name)
+		if err != nil {
+			return
+		}
+		p := filepath.Join(base, s)
+		if filepath.Dir(p) != base || filepath.Base(p) != s {
+			t.Fatalf("%q escapes %s: %s", name, base, p)
+		}
+	})
+}
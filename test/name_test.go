@@ -0,0 +1,49 @@
+package fcntllock_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestSanitizeName(t *testing.T) {
+	for _, name := range []string{"lck", "my.lock", "..lck", "a b", "élan"} {
+		t.Run("accepts "+name, func(t *testing.T) {
+			s, err := fcntllock.SanitizeName(name)
+			require.NoError(t, err)
+			require.Equal(t, name, s)
+		})
+	}
+
+	dangerous := []string{
+		"",
+		".",
+		"..",
+		"../lck",
+		"a/../../lck",
+		"/etc/passwd",
+		`..\lck`,
+		"lck\x00.txt",
+		"lck\n",
+		strings.Repeat("a", 256),
+	}
+	for _, name := range dangerous {
+		t.Run("rejects "+name, func(t *testing.T) {
+			_, err := fcntllock.SanitizeName(name)
+			require.ErrorIs(t, err, fcntllock.ErrInvalidName)
+		})
+	}
+}
+
+func TestNewUnder(t *testing.T) {
+	l, err := fcntllock.NewUnder("/run/app", "lck", fcntllock.WithSuffix(".lock"))
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("/run/app", "lck.lock"), l.(*fcntllock.Lock).Path())
+
+	_, err = fcntllock.NewUnder("/run/app", "../lck")
+	require.ErrorIs(t, err, fcntllock.ErrInvalidName)
+}
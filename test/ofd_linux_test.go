@@ -0,0 +1,59 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithOFD(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+
+	t.Run("OFD locks conflict within the process", func(t *testing.T) {
+		l1 := fcntllock.New(lockfile, fcntllock.WithOFD(true))
+		l2 := fcntllock.New(lockfile, fcntllock.WithOFD(true))
+		require.NoError(t, l1.TryLock())
+		require.Error(t, l2.TryLock())
+		require.NoError(t, l1.UnLock())
+		require.NoError(t, l2.TryLock())
+		require.NoError(t, l2.UnLock())
+	})
+
+	t.Run("classic locks do not conflict within the process", func(t *testing.T) {
+		l1 := fcntllock.New(lockfile)
+		l2 := fcntllock.New(lockfile)
+		require.NoError(t, l1.TryLock())
+		require.NoError(t, l2.TryLock())
+		require.NoError(t, l2.UnLock())
+		require.NoError(t, l1.UnLock())
+	})
+}
+
+func TestUpgradeOFD(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+	r1 := fcntllock.New(lockfile, fcntllock.WithOFD(true)).(*fcntllock.Lock)
+	r2 := fcntllock.New(lockfile, fcntllock.WithOFD(true)).(*fcntllock.Lock)
+	require.NoError(t, r1.TryRLock())
+	require.NoError(t, r2.TryRLock())
+
+	released := make(chan error, 1)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		released <- r1.UnLock()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, r2.Upgrade(ctx, 5*time.Millisecond))
+	require.NoError(t, <-released)
+	typ, _ := r2.LockType()
+	require.Equal(t, fcntllock.Exclusive, typ)
+	require.Error(t, r1.TryRLock())
+	require.NoError(t, r2.UnLock())
+}
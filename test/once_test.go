@@ -0,0 +1,44 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestAcquireOnce(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	var acquired int
+	l := fcntllock.New(lockfile, fcntllock.WithPostAcquire(func(*fcntllock.Lock) error {
+		acquired++
+		return nil
+	})).(*fcntllock.Lock)
+	ctx := context.Background()
+
+	t.Run("repeated token is a no-op while held", func(t *testing.T) {
+		require.NoError(t, l.AcquireOnce("req-1", ctx, 10*time.Millisecond))
+		require.NoError(t, l.AcquireOnce("req-1", ctx, 10*time.Millisecond))
+		require.Equal(t, 1, acquired)
+		require.True(t, l.HeldByMe())
+	})
+
+	t.Run("other token is a new acquisition", func(t *testing.T) {
+		require.NoError(t, l.AcquireOnce("req-2", ctx, 10*time.Millisecond))
+		require.Equal(t, 2, acquired)
+	})
+
+	t.Run("token is forgotten on release", func(t *testing.T) {
+		released, err := l.UnLockResult()
+		require.NoError(t, err)
+		require.True(t, released)
+		require.NoError(t, l.AcquireOnce("req-2", ctx, 10*time.Millisecond))
+		require.Equal(t, 3, acquired)
+		require.NoError(t, l.UnLock())
+	})
+}
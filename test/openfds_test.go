@@ -0,0 +1,61 @@
+package fcntllock_test
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestOpenFDs(t *testing.T) {
+	lockDir, cleanup := testhelper.Tempdir(t)
+	defer cleanup()
+	base := fcntllock.OpenFDs()
+
+	var locks []*fcntllock.Lock
+	for i := 0; i < 5; i++ {
+		l := fcntllock.New(filepath.Join(lockDir, strconv.Itoa(i))).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		locks = append(locks, l)
+	}
+	require.Equal(t, base+5, fcntllock.OpenFDs())
+
+	t.Run("UnLock keeps the lock file open", func(t *testing.T) {
+		require.NoError(t, locks[0].UnLock())
+		require.Equal(t, base+5, fcntllock.OpenFDs())
+		require.NoError(t, locks[0].TryLock())
+		require.Equal(t, base+5, fcntllock.OpenFDs(), "the open lock file is reused")
+	})
+
+	t.Run("Close and Handle Close release", func(t *testing.T) {
+		require.NoError(t, locks[0].Close())
+		require.NoError(t, locks[0].Close())
+		require.Equal(t, base+4, fcntllock.OpenFDs())
+		require.NoError(t, locks[1].Handle().Close())
+		require.Equal(t, base+3, fcntllock.OpenFDs())
+	})
+
+	t.Run("cap is enforced", func(t *testing.T) {
+		fcntllock.SetMaxOpenLocks(base + 4)
+		defer fcntllock.SetMaxOpenLocks(0)
+		l := fcntllock.New(filepath.Join(lockDir, "a")).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		require.Equal(t, base+4, fcntllock.OpenFDs())
+		extra := fcntllock.New(filepath.Join(lockDir, "b")).(*fcntllock.Lock)
+		require.ErrorIs(t, extra.TryLock(), fcntllock.ErrTooManyOpenLocks)
+		require.Equal(t, base+4, fcntllock.OpenFDs())
+
+		require.NoError(t, l.Close())
+		require.NoError(t, extra.TryLock())
+		require.NoError(t, extra.Close())
+	})
+
+	for _, l := range locks {
+		require.NoError(t, l.Close())
+	}
+	require.Equal(t, base, fcntllock.OpenFDs())
+}
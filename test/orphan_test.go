@@ -0,0 +1,31 @@
+package fcntllock_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithReleaseOnOrphan(t *testing.T) {
+	lockDir, cleanup := testhelper.Tempdir(t)
+	defer cleanup()
+	lockfile := filepath.Join(lockDir, "lck")
+	ready := filepath.Join(lockDir, "ready")
+
+	// the forked parent exits once its child holds the lock, the orphaned
+	// child keeps running 3 seconds
+	forkCmd := lockInFork("OrphanParent", lockfile, ready)
+	require.NoError(t, forkCmd.Start())
+	require.NoError(t, forkCmd.Wait())
+
+	l := fcntllock.New(lockfile)
+	require.Error(t, l.TryLock(), "the child holds the lock")
+	require.Eventually(t, func() bool { return l.TryLock() == nil }, 2*time.Second, 20*time.Millisecond,
+		"the orphaned child releases the lock")
+	require.NoError(t, l.UnLock())
+}
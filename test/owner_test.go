@@ -0,0 +1,40 @@
+package fcntllock_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestOwner(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	l := fcntllock.New(lockfile).(*fcntllock.Lock)
+	uid, gid, err := l.Owner()
+	require.NoError(t, err)
+	require.Equal(t, os.Getuid(), uid)
+	require.Equal(t, os.Getgid(), gid)
+	require.False(t, l.HeldByMe())
+}
+
+func TestWithRequireOwner(t *testing.T) {
+	t.Run("lock file owned by the expected user is accepted", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithRequireOwner(os.Getuid()))
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("lock file owned by another user is rejected", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithRequireOwner(os.Getuid()+1)).(*fcntllock.Lock)
+		require.ErrorIs(t, l.TryLock(), fcntllock.ErrWrongOwner)
+		require.False(t, l.HeldByMe())
+	})
+}
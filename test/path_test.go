@@ -0,0 +1,33 @@
+package fcntllock_test
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestPathForKey(t *testing.T) {
+	t.Run("same key gives same path", func(t *testing.T) {
+		require.Equal(t,
+			fcntllock.PathForKey("/run/app", "https://example.com/a?b=c"),
+			fcntllock.PathForKey("/run/app", "https://example.com/a?b=c"))
+	})
+
+	t.Run("different keys give different paths", func(t *testing.T) {
+		require.NotEqual(t,
+			fcntllock.PathForKey("/run/app", "obj1"),
+			fcntllock.PathForKey("/run/app", "obj2"))
+	})
+
+	t.Run("path is under dir with safe characters only", func(t *testing.T) {
+		for _, key := range []string{"", "../../etc/passwd", "a/b\x00c", string(make([]byte, 4096))} {
+			p := fcntllock.PathForKey("/run/app", key)
+			require.Equal(t, "/run/app", filepath.Dir(p))
+			require.Regexp(t, regexp.MustCompile(`^[0-9a-f]+$`), filepath.Base(p))
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package fcntllock_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLockPool(t *testing.T) {
+	t.Run("reused lock is reset", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		p := fcntllock.NewLockPool(fcntllock.WithSuffix(".lock"))
+		l := p.Get(filepath.Join(lockDir, "a"))
+		require.Equal(t, filepath.Join(lockDir, "a.lock"), l.Path())
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.UnLock())
+		require.NoError(t, p.Put(l))
+
+		l = p.Get(filepath.Join(lockDir, "b"))
+		require.Equal(t, filepath.Join(lockDir, "b.lock"), l.Path())
+		require.False(t, l.HeldByMe())
+		require.NoError(t, l.TryLock())
+		require.NoError(t, p.Put(l))
+	})
+
+	t.Run("put releases a still held lock and closes its file", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		p := fcntllock.NewLockPool()
+		fds := fcntllock.OpenFDs()
+		l := p.Get(lockfile)
+		require.NoError(t, l.TryLock())
+		require.Equal(t, fds+1, fcntllock.OpenFDs())
+		require.NoError(t, p.Put(l))
+		require.Equal(t, fds, fcntllock.OpenFDs())
+
+		// another process can get the lock
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("get allocates less than new", func(t *testing.T) {
+		p := fcntllock.NewLockPool()
+		p.Get("/tmp/lck")
+		poolAllocs := testing.AllocsPerRun(100, func() {
+			_ = p.Put(p.Get("/tmp/lck"))
+		})
+		newAllocs := testing.AllocsPerRun(100, func() {
+			_ = fcntllock.New("/tmp/lck").(*fcntllock.Lock).Close()
+		})
+		require.Less(t, poolAllocs, newAllocs)
+	})
+}
+
+func benchmarkLockCycle(b *testing.B, get func(path string) *fcntllock.Lock, put func(l *fcntllock.Lock)) {
+	lockDir, err := ioutil.TempDir("", "fcntllock-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(lockDir) }()
+	lockfile := filepath.Join(lockDir, "lck")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := get(lockfile)
+		if err := l.TryLock(); err != nil {
+			b.Fatal(err)
+		}
+		put(l)
+	}
+}
+
+func BenchmarkLockPool(b *testing.B) {
+	p := fcntllock.NewLockPool()
+	benchmarkLockCycle(b, p.Get, func(l *fcntllock.Lock) {
+		if err := p.Put(l); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+func BenchmarkNew(b *testing.B) {
+	benchmarkLockCycle(b, func(path string) *fcntllock.Lock {
+		return fcntllock.New(path).(*fcntllock.Lock)
+	}, func(l *fcntllock.Lock) {
+		if err := l.UnLock(); err != nil {
+			b.Fatal(err)
+		}
+		if err := l.Close(); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLockContextPreempt(t *testing.T) {
+	t.Run("free lock is acquired", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		preempt := make(chan struct{})
+		require.NoError(t, l.LockContextPreempt(context.Background(), 10*time.Millisecond, preempt))
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("preempt fires mid-wait", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// start in fork a lock and holds it during 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		defer func() { _ = forkCmd.Wait() }()
+		time.Sleep(50 * time.Millisecond)
+
+		preempt := make(chan struct{})
+		time.AfterFunc(20*time.Millisecond, func() { close(preempt) })
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		begin := time.Now()
+		err := l.LockContextPreempt(ctx, 10*time.Millisecond, preempt)
+		require.ErrorIs(t, err, fcntllock.ErrPreempted)
+		require.Less(t, int64(time.Since(begin)), int64(45*time.Millisecond))
+		require.False(t, l.HeldByMe())
+	})
+
+	t.Run("context end is still reported", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		defer func() { _ = forkCmd.Wait() }()
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := l.LockContextPreempt(ctx, 10*time.Millisecond, make(chan struct{}))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
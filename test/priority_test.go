@@ -0,0 +1,101 @@
+package fcntllock_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestAcquireWithPriority(t *testing.T) {
+	t.Run("highest priority waiter wins locally", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		holder := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, holder.AcquireWithPriority(context.Background(), 10*time.Millisecond, 0))
+
+		var (
+			mu    sync.Mutex
+			order []int
+			wg    sync.WaitGroup
+		)
+		for _, priority := range []int{1, 5, 3, 5, -1} {
+			wg.Add(1)
+			go func(priority int) {
+				defer wg.Done()
+				l := fcntllock.New(lockfile).(*fcntllock.Lock)
+				if err := l.AcquireWithPriority(context.Background(), 10*time.Millisecond, priority); err != nil {
+					t.Errorf("priority %d: %s", priority, err)
+					return
+				}
+				mu.Lock()
+				order = append(order, priority)
+				mu.Unlock()
+				time.Sleep(5 * time.Millisecond)
+				_ = l.UnLock()
+			}(priority)
+			// waiter arrival order
+			time.Sleep(5 * time.Millisecond)
+		}
+		require.NoError(t, holder.UnLock())
+		wg.Wait()
+		require.Equal(t, []int{5, 5, 3, 1, -1}, order)
+	})
+
+	t.Run("cancelled waiter leaves the queue", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		holder := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, holder.AcquireWithPriority(context.Background(), 10*time.Millisecond, 0))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.ErrorIs(t, l.AcquireWithPriority(ctx, 10*time.Millisecond, 10), context.DeadlineExceeded)
+		require.False(t, l.HeldByMe())
+
+		require.NoError(t, holder.UnLock())
+		require.NoError(t, l.AcquireWithPriority(context.Background(), 10*time.Millisecond, 0))
+		require.NoError(t, l.UnLock())
+	})
+}
+
+func TestAcquireWithPrioritySymlink(t *testing.T) {
+	lockDir, cleanup := testhelper.Tempdir(t)
+	defer cleanup()
+	target := filepath.Join(lockDir, "real")
+	link := filepath.Join(lockDir, "link")
+	require.NoError(t, os.Symlink(target, link), "the target doesn't exist yet")
+
+	linkDir := filepath.Join(lockDir, "linkdir")
+	require.NoError(t, os.Symlink(lockDir, linkDir))
+
+	for name, other := range map[string]string{
+		"symlinked file":      link,
+		"symlinked directory": filepath.Join(linkDir, "real"),
+		"unclean path":        filepath.Join(lockDir, ".", "sub", "..", "real"),
+	} {
+		other := other
+		t.Run(name, func(t *testing.T) {
+			holder := fcntllock.New(target).(*fcntllock.Lock)
+			require.NoError(t, holder.AcquireWithPriority(context.Background(), 10*time.Millisecond, 0))
+
+			l := fcntllock.New(other).(*fcntllock.Lock)
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+			defer cancel()
+			require.ErrorIs(t, l.AcquireWithPriority(ctx, 10*time.Millisecond, 0), context.DeadlineExceeded,
+				"%s must share the in-process lock of %s", other, target)
+
+			require.NoError(t, holder.UnLock())
+			require.NoError(t, l.AcquireWithPriority(context.Background(), 10*time.Millisecond, 0))
+			require.NoError(t, l.UnLock())
+		})
+	}
+}
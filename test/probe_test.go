@@ -0,0 +1,120 @@
+package fcntllock_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestScanDir(t *testing.T) {
+	lockDir, cleanup := testhelper.Tempdir(t)
+	defer cleanup()
+	held := filepath.Join(lockDir, "held")
+	shared := filepath.Join(lockDir, "shared")
+	free := filepath.Join(lockDir, "free")
+	unreadable := filepath.Join(lockDir, "unreadable")
+	for _, p := range []string{free, unreadable} {
+		require.NoError(t, ioutil.WriteFile(p, nil, 0600))
+	}
+	require.NoError(t, os.Chmod(unreadable, 0))
+	require.NoError(t, os.Mkdir(filepath.Join(lockDir, "subdir"), 0700))
+
+	// start in fork locks held during 102 milliseconds
+	heldCmd := lockInFork("TryLock", held)
+	require.NoError(t, heldCmd.Start())
+	sharedCmd := lockInFork("TryRLock", shared)
+	require.NoError(t, sharedCmd.Start())
+	time.Sleep(50 * time.Millisecond)
+
+	l, err := fcntllock.ScanDir(lockDir)
+	require.NoError(t, err)
+	require.NoError(t, heldCmd.Wait())
+	require.NoError(t, sharedCmd.Wait())
+
+	statuses := make(map[string]fcntllock.LockStatus)
+	for _, status := range l {
+		statuses[status.Path] = status
+	}
+	require.Len(t, statuses, 4, "subdir must be skipped")
+
+	require.True(t, statuses[held].Held)
+	require.Equal(t, fcntllock.Exclusive, statuses[held].Type)
+	require.Equal(t, heldCmd.Process.Pid, statuses[held].PID)
+	require.NoError(t, statuses[held].Err)
+
+	require.True(t, statuses[shared].Held)
+	require.Equal(t, fcntllock.Shared, statuses[shared].Type)
+	require.Equal(t, sharedCmd.Process.Pid, statuses[shared].PID)
+
+	require.False(t, statuses[free].Held)
+	require.NoError(t, statuses[free].Err)
+
+	if os.Geteuid() != 0 {
+		require.ErrorIs(t, statuses[unreadable].Err, os.ErrPermission)
+	}
+	require.False(t, statuses[unreadable].Held)
+
+	_, err = fcntllock.ScanDir(filepath.Join(lockDir, "missing"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestProbeOwnLock(t *testing.T) {
+	lockDir, cleanup := testhelper.Tempdir(t)
+	defer cleanup()
+	lockfile := filepath.Join(lockDir, "lck")
+	link := filepath.Join(lockDir, "link")
+	l := fcntllock.New(lockfile, fcntllock.WithMetadata()).(*fcntllock.Lock)
+	require.NoError(t, l.TryLock())
+	defer func() { _ = l.UnLock() }()
+	require.NoError(t, os.Symlink(lockfile, link))
+
+	for name, probe := range map[string]func() error{
+		"Probe": func() error {
+			status, err := fcntllock.Probe(lockfile)
+			require.False(t, status.Held, "the own locks are never reported")
+			return err
+		},
+		"Probe through a symlink": func() error {
+			_, err := fcntllock.Probe(link)
+			return err
+		},
+		"ScanDir": func() error {
+			_, err := fcntllock.ScanDir(lockDir)
+			return err
+		},
+		"ReadMetadata": func() error {
+			m, err := fcntllock.ReadMetadata(lockfile)
+			if err == nil {
+				require.Equal(t, os.Getpid(), m.PID)
+			}
+			return err
+		},
+		"Status of another Lock": func() error {
+			_, err := fcntllock.New(lockfile).(*fcntllock.Lock).Status()
+			return err
+		},
+		"WaitForUnlock of another Lock": func() error {
+			return fcntllock.New(lockfile).(*fcntllock.Lock).WaitForUnlock(context.Background(), time.Millisecond)
+		},
+		"WouldGrant of another Lock": func() error {
+			_, _, err := fcntllock.New(lockfile).(*fcntllock.Lock).WouldGrant(fcntllock.Exclusive)
+			return err
+		},
+	} {
+		t.Run(name+" keeps the prober lock", func(t *testing.T) {
+			require.NoError(t, probe())
+			forkCmd := lockInFork("TryLock", lockfile)
+			require.NoError(t, forkCmd.Start())
+			require.Error(t, forkCmd.Wait(), "expected the lock to be still held")
+			require.True(t, l.HeldByMe())
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package fcntllock_test
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestRawFdOFD(t *testing.T) {
+	t.Run("OFD lock handed off to a child process survives the sender release", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		sender := fcntllock.New(lockfile, fcntllock.WithOFD(true)).(*fcntllock.Lock)
+		require.NoError(t, sender.TryLock())
+
+		forkCmd := handOffInFork(t, sender, "ofd")
+
+		// the sender closes its descriptor, without unlocking the shared
+		// open file description
+		require.NoError(t, sender.Close())
+		require.False(t, rangeLockable(t, lockfile, 0, 0), "expected the receiver to hold the lock")
+
+		require.NoError(t, forkCmd.Process.Signal(syscall.SIGUSR1))
+		require.NoError(t, forkCmd.Wait(), "expected the receiver to release the lock")
+		require.True(t, rangeLockable(t, lockfile, 0, 0))
+	})
+
+	t.Run("OFD lock passed over a socketpair is held by the receiver", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		sender := fcntllock.New(lockfile, fcntllock.WithOFD(true)).(*fcntllock.Lock)
+		require.NoError(t, sender.TryLock())
+		fd, err := sender.RawFd()
+		require.NoError(t, err)
+
+		pair, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+		require.NoError(t, err)
+		defer func() { _ = syscall.Close(pair[0]) }()
+		defer func() { _ = syscall.Close(pair[1]) }()
+		require.NoError(t, syscall.Sendmsg(pair[0], []byte{0}, syscall.UnixRights(int(fd)), nil, 0))
+		oob := make([]byte, syscall.CmsgSpace(4))
+		_, oobn, _, _, err := syscall.Recvmsg(pair[1], make([]byte, 1), oob, 0)
+		require.NoError(t, err)
+		msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		fds, err := syscall.ParseUnixRights(&msgs[0])
+		require.NoError(t, err)
+		require.Len(t, fds, 1)
+
+		receiver := fcntllock.FromRawFd(uintptr(fds[0]), lockfile, true, fcntllock.WithOFD(true)).(*fcntllock.Lock)
+		require.True(t, receiver.HeldByMe())
+		require.NoError(t, sender.Close())
+		require.False(t, rangeLockable(t, lockfile, 0, 0), "expected the receiver to hold the lock")
+
+		require.NoError(t, receiver.UnLock())
+		require.NoError(t, receiver.Close())
+		require.True(t, rangeLockable(t, lockfile, 0, 0))
+	})
+}
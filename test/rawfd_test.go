@@ -0,0 +1,77 @@
+package fcntllock_test
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestRawFd(t *testing.T) {
+	t.Run("not held", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		_, err := fcntllock.New(lockfile).(*fcntllock.Lock).RawFd()
+		require.ErrorIs(t, err, fcntllock.ErrNotLocked)
+	})
+
+	t.Run("FromRawFd records the held lock type", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		fd, err := syscall.Open(lockfile, syscall.O_RDWR, 0)
+		require.NoError(t, err)
+		l := fcntllock.FromRawFd(uintptr(fd), lockfile, true, fcntllock.WithHeldType(fcntllock.Shared)).(*fcntllock.Lock)
+		require.True(t, l.HeldByMe())
+		require.Equal(t, lockfile, l.Path())
+		typ, held := l.LockType()
+		require.True(t, held)
+		require.Equal(t, fcntllock.Shared, typ)
+		require.NoError(t, l.Close())
+	})
+
+	t.Run("FromRawFd defaults to an exclusive lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		fd, err := syscall.Open(lockfile, syscall.O_RDWR, 0)
+		require.NoError(t, err)
+		l := fcntllock.FromRawFd(uintptr(fd), lockfile, true).(*fcntllock.Lock)
+		typ, held := l.LockType()
+		require.True(t, held)
+		require.Equal(t, fcntllock.Exclusive, typ)
+		require.NoError(t, l.Close())
+	})
+
+	t.Run("classic lock is not handed off to a child process", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		sender := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, sender.TryLock())
+
+		forkCmd := handOffInFork(t, sender, "classic")
+		defer func() { _ = forkCmd.Wait() }()
+		defer func() { _ = forkCmd.Process.Signal(syscall.SIGUSR1) }()
+
+		require.NoError(t, sender.UnLock())
+		require.NoError(t, sender.Close())
+		require.True(t, rangeLockable(t, lockfile, 0, 0), "the classic lock is owned by the sender process")
+	})
+}
+
+// handOffInFork starts a HoldRawFd helper process reconstructing the lock
+// of lck from a duplicate of its lock file descriptor, with the kind lock
+// options, and returns once the lock is reconstructed.
+func handOffInFork(t *testing.T, lck *fcntllock.Lock, kind string) *exec.Cmd {
+	t.Helper()
+	fd, err := lck.RawFd()
+	require.NoError(t, err)
+	dupFd, err := syscall.Dup(int(fd))
+	require.NoError(t, err)
+	f := os.NewFile(uintptr(dupFd), lck.Path())
+	defer func() { _ = f.Close() }()
+	return startLockInForkWith(t, []*os.File{f}, "HoldRawFd", lck.Path(), kind)
+}
@@ -0,0 +1,84 @@
+package fcntllock_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestReset(t *testing.T) {
+	t.Run("truncates garbage while holding the lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, []byte("\x00garbage\xff"), 0600))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.Reset())
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Empty(t, b)
+
+		_, err = l.Write([]byte("fresh"))
+		require.NoError(t, err)
+		b, err = ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "fresh", string(b))
+	})
+
+	t.Run("rewrites the identity metadata", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithIdentity("backup")).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.Reset())
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "backup", m.Identity)
+	})
+
+	t.Run("rewrites the reason metadata", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.LockReason(context.Background(), 10*time.Millisecond, "running backup"))
+		require.NoError(t, l.Reset())
+		m, err := fcntllock.ReadMetadata(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "running backup", m.Reason)
+	})
+
+	t.Run("returns ErrNoFd without lock file", func(t *testing.T) {
+		l := fcntllock.New("/no-such-dir/mem.lock", fcntllock.WithBackend(newMemBackend())).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		require.ErrorIs(t, l.Reset(), fcntllock.ErrNoFd)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("returns ErrNotLocked when not held", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.ErrorIs(t, l.Reset(), fcntllock.ErrNotLocked)
+
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.UnLock())
+		require.ErrorIs(t, l.Reset(), fcntllock.ErrNotLocked)
+	})
+}
+
+func TestHeldByMe(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	l := fcntllock.New(lockfile).(*fcntllock.Lock)
+	require.False(t, l.HeldByMe())
+	require.NoError(t, l.TryLock())
+	require.True(t, l.HeldByMe())
+	require.NoError(t, l.UnLock())
+	require.False(t, l.HeldByMe())
+}
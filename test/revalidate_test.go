@@ -0,0 +1,84 @@
+package fcntllock_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestRevalidate(t *testing.T) {
+	t.Run("not held", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.ErrorIs(t, l.Revalidate(), fcntllock.ErrNotLocked)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		require.NoError(t, l.Revalidate())
+	})
+
+	t.Run("shared lock next to another reader", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryRLock())
+		defer func() { _ = l.UnLock() }()
+
+		forkCmd := startLockInFork(t, "TryRLock", lockfile)
+		require.NoError(t, l.Revalidate())
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("range lock next to another range lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.LockFrom(10))
+		defer func() { _ = l.UnLock() }()
+
+		forkCmd := startLockInFork(t, "HoldRange", lockfile, "50", "10")
+		require.NoError(t, l.Revalidate())
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("lock lost to another process", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+
+		// closing any file descriptor of the lock file releases the
+		// process locks
+		f, err := os.Open(lockfile)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+		require.ErrorIs(t, l.Revalidate(), fcntllock.ErrLockLost)
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("lock file removed", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		require.NoError(t, os.Remove(lockfile))
+		require.ErrorIs(t, l.Revalidate(), fcntllock.ErrLockLost)
+	})
+}
@@ -0,0 +1,90 @@
+package fcntllock_test
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+// countingFile is an instrumented *os.File counting the written bytes
+type countingFile struct {
+	*os.File
+	written int
+}
+
+func (f *countingFile) Write(b []byte) (int, error) {
+	n, err := f.File.Write(b)
+	f.written += n
+	return n, err
+}
+
+// noFdFile is a ReadWriteSeekCloser without file descriptor
+type noFdFile struct {
+	io.ReadWriteSeeker
+	io.Closer
+}
+
+func TestNewFromRWSC(t *testing.T) {
+	t.Run("instrumented wrapper", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		file, err := os.OpenFile(lockfile, os.O_RDWR, 0)
+		require.NoError(t, err)
+		f := &countingFile{File: file}
+		defer func() { _ = f.Close() }()
+
+		l, err := fcntllock.NewFromRWSC(lockfile, f)
+		require.NoError(t, err)
+		require.NoError(t, l.TryLock())
+		require.False(t, rangeLockable(t, lockfile, 0, 0))
+
+		lck := l.(*fcntllock.Lock)
+		_, err = lck.Write([]byte("data"))
+		require.NoError(t, err)
+		require.Equal(t, 4, f.written, "writes must go through the wrapper")
+		require.NoError(t, l.UnLock())
+		require.True(t, rangeLockable(t, lockfile, 0, 0))
+	})
+
+	t.Run("lock failure keeps the caller file open", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		file, err := os.OpenFile(lockfile, os.O_RDWR, 0)
+		require.NoError(t, err)
+		f := &countingFile{File: file}
+		defer func() { _ = f.Close() }()
+
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+		l, err := fcntllock.NewFromRWSC(lockfile, f)
+		require.NoError(t, err)
+		require.Error(t, l.TryLock())
+		require.NoError(t, forkCmd.Wait())
+
+		require.NoError(t, l.TryLock())
+		require.Same(t, f, l.(*fcntllock.Lock).ReadWriteSeekCloser)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("no fd", func(t *testing.T) {
+		_, err := fcntllock.NewFromRWSC("/tmp/x", noFdFile{})
+		require.ErrorIs(t, err, fcntllock.ErrNoFd)
+	})
+
+	t.Run("closed file", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		file, err := os.OpenFile(lockfile, os.O_RDWR, 0)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+		_, err = fcntllock.NewFromRWSC(lockfile, file)
+		require.ErrorIs(t, err, fcntllock.ErrNoFd)
+	})
+}
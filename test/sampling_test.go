@@ -0,0 +1,64 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithContentionSampling(t *testing.T) {
+	t.Run("contended acquisition stack is captured once", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		var stacks [][]byte
+		l := fcntllock.New(lockfile, fcntllock.WithContentionSampling(1, func(stack []byte) {
+			stacks = append(stacks, stack)
+		}))
+
+		// the forked lock process holds the lock 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, l.LockContext(ctx, 5*time.Millisecond))
+		require.NoError(t, forkCmd.Wait())
+		require.NoError(t, l.UnLock())
+		require.Len(t, stacks, 1)
+		require.Contains(t, string(stacks[0]), "TestWithContentionSampling")
+	})
+
+	t.Run("uncontended acquisition is not captured", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		var calls int
+		l := fcntllock.New(lockfile, fcntllock.WithContentionSampling(1, func([]byte) { calls++ }))
+		require.NoError(t, l.LockContext(context.Background(), 5*time.Millisecond))
+		require.NoError(t, l.UnLock())
+		require.Equal(t, 0, calls)
+	})
+
+	t.Run("zero rate disables the sampling", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		var calls int
+		l := fcntllock.New(lockfile, fcntllock.WithContentionSampling(0, func([]byte) { calls++ }))
+
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, l.LockContext(ctx, 5*time.Millisecond))
+		require.NoError(t, forkCmd.Wait())
+		require.NoError(t, l.UnLock())
+		require.Equal(t, 0, calls)
+	})
+}
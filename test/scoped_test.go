@@ -0,0 +1,68 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLockScoped(t *testing.T) {
+	t.Run("parent cancel releases the lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		parent, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		scoped, release, err := l.LockScoped(parent, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.False(t, rangeLockable(t, lockfile, 0, 0))
+
+		cancel()
+		select {
+		case <-scoped.Done():
+		case <-time.After(time.Second):
+			t.Fatal("scoped context not done")
+		}
+		release()
+		require.False(t, l.HeldByMe())
+		require.True(t, rangeLockable(t, lockfile, 0, 0))
+	})
+
+	t.Run("manual release", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		scoped, release, err := l.LockScoped(context.Background(), 10*time.Millisecond)
+		require.NoError(t, err)
+		release()
+		require.Error(t, scoped.Err(), "the scoped context must be cancelled")
+		require.False(t, l.HeldByMe())
+		require.True(t, rangeLockable(t, lockfile, 0, 0))
+
+		// the lock is released only once
+		require.NoError(t, l.TryLock())
+		release()
+		require.True(t, l.HeldByMe())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("acquisition failure", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, _, err := fcntllock.New(lockfile).(*fcntllock.Lock).LockScoped(ctx, 10*time.Millisecond)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NoError(t, forkCmd.Wait())
+	})
+}
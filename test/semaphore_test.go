@@ -0,0 +1,63 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestSemaphore(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	const n = 3
+	s := fcntllock.NewSemaphore(lockfile, n, 10*time.Millisecond)
+	defer func() { _ = s.Close() }()
+
+	slots := make(map[int]bool)
+	t.Run("n acquirers succeed on distinct slots", func(t *testing.T) {
+		for i := 0; i < n; i++ {
+			slot, err := s.Acquire(context.Background())
+			require.NoError(t, err)
+			require.False(t, slots[slot], "slot %d acquired twice", slot)
+			slots[slot] = true
+		}
+		for slot := range slots {
+			require.False(t, rangeLockable(t, lockfile, int64(slot), 1),
+				"slot %d must be locked for other processes", slot)
+		}
+	})
+
+	t.Run("n+1th acquirer waits for a release", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := s.Acquire(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		go func() {
+			time.Sleep(30 * time.Millisecond)
+			_ = s.Release(1)
+		}()
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		slot, err := s.Acquire(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, slot)
+	})
+
+	t.Run("release of a slot not held", func(t *testing.T) {
+		require.NoError(t, s.Release(0))
+		require.True(t, rangeLockable(t, lockfile, 0, 1))
+		require.ErrorIs(t, s.Release(0), fcntllock.ErrNotLocked)
+		require.ErrorIs(t, s.Release(n), fcntllock.ErrNotLocked)
+	})
+
+	t.Run("close releases all slots", func(t *testing.T) {
+		require.NoError(t, s.Close())
+		require.True(t, rangeLockable(t, lockfile, 0, n))
+	})
+}
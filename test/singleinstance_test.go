@@ -0,0 +1,116 @@
+package fcntllock_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestSingleInstance(t *testing.T) {
+	runtimeDir, cleanup := testhelper.Tempdir(t)
+	defer cleanup()
+	prev, ok := os.LookupEnv("XDG_RUNTIME_DIR")
+	require.NoError(t, os.Setenv("XDG_RUNTIME_DIR", runtimeDir))
+	defer func() {
+		if ok {
+			_ = os.Setenv("XDG_RUNTIME_DIR", prev)
+		} else {
+			_ = os.Unsetenv("XDG_RUNTIME_DIR")
+		}
+	}()
+
+	t.Run("first instance", func(t *testing.T) {
+		l, err := fcntllock.SingleInstance("mycli")
+		require.NoError(t, err)
+		require.FileExists(t, filepath.Join(runtimeDir, "mycli.lock"))
+		require.True(t, l.(*fcntllock.Lock).HeldByMe())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("second instance is rejected with the first instance pid", func(t *testing.T) {
+		// the forked first instance holds the lock 102 milliseconds
+		forkCmd := lockInFork("SingleInstance", "mycli")
+		forkCmd.Env = append(forkCmd.Env, "XDG_RUNTIME_DIR="+runtimeDir)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		l, err := fcntllock.SingleInstance("mycli")
+		require.Nil(t, l)
+		require.ErrorIs(t, err, fcntllock.ErrAlreadyRunning)
+		var runningErr *fcntllock.AlreadyRunningError
+		require.True(t, errors.As(err, &runningErr))
+		require.Equal(t, forkCmd.Process.Pid, runningErr.PID)
+		require.Contains(t, err.Error(), "pid")
+		require.NoError(t, forkCmd.Wait())
+
+		l, err = fcntllock.SingleInstance("mycli")
+		require.NoError(t, err)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		_, err := fcntllock.SingleInstance("../mycli")
+		require.ErrorIs(t, err, fcntllock.ErrInvalidName)
+	})
+}
+
+func TestSingleInstanceSharedTempDir(t *testing.T) {
+	tmpDir, cleanup := testhelper.Tempdir(t)
+	defer cleanup()
+	for key, value := range map[string]string{"XDG_RUNTIME_DIR": "", "TMPDIR": tmpDir} {
+		prev, ok := os.LookupEnv(key)
+		if value == "" {
+			require.NoError(t, os.Unsetenv(key))
+		} else {
+			require.NoError(t, os.Setenv(key, value))
+		}
+		defer func(key string) {
+			if ok {
+				_ = os.Setenv(key, prev)
+			} else {
+				_ = os.Unsetenv(key)
+			}
+		}(key)
+	}
+	lockfile := filepath.Join(tmpDir, fmt.Sprintf("mycli.%d.lock", os.Getuid()))
+
+	t.Run("own lock file", func(t *testing.T) {
+		l, err := fcntllock.SingleInstance("mycli")
+		require.NoError(t, err)
+		require.FileExists(t, lockfile)
+		require.NoError(t, l.UnLock())
+		require.NoError(t, os.Remove(lockfile))
+	})
+
+	t.Run("planted symlink is rejected", func(t *testing.T) {
+		target := filepath.Join(tmpDir, "target")
+		require.NoError(t, os.Symlink(target, lockfile))
+		defer func() { _ = os.Remove(lockfile) }()
+		l, err := fcntllock.SingleInstance("mycli")
+		require.Nil(t, l)
+		require.ErrorIs(t, err, syscall.ELOOP)
+		require.NoFileExists(t, target)
+	})
+
+	t.Run("lock file of another user is rejected", func(t *testing.T) {
+		if os.Getuid() != 0 {
+			t.Skip("requires root to plant a file of another user")
+		}
+		require.NoError(t, ioutil.WriteFile(lockfile, nil, 0666))
+		defer func() { _ = os.Remove(lockfile) }()
+		require.NoError(t, os.Chown(lockfile, 1, 1))
+		l, err := fcntllock.SingleInstance("mycli")
+		require.Nil(t, l)
+		require.ErrorIs(t, err, fcntllock.ErrWrongOwner)
+	})
+}
@@ -0,0 +1,49 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithSlowWaitThreshold(t *testing.T) {
+	t.Run("fires once while the lock is held by another process", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		slow := make(chan time.Duration, 2)
+		l := fcntllock.New(lockfile, fcntllock.WithSlowWaitThreshold(20*time.Millisecond, func(waited time.Duration) {
+			slow <- waited
+		}))
+
+		// the forked lock process holds the lock 102 milliseconds
+		forkCmd := lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, l.LockContext(ctx, 5*time.Millisecond))
+		require.NoError(t, forkCmd.Wait())
+		require.NoError(t, l.UnLock())
+		require.Len(t, slow, 1)
+		require.GreaterOrEqual(t, int64(<-slow), int64(20*time.Millisecond))
+	})
+
+	t.Run("does not fire for a fast acquisition", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		slow := make(chan time.Duration, 1)
+		l := fcntllock.New(lockfile, fcntllock.WithSlowWaitThreshold(20*time.Millisecond, func(waited time.Duration) {
+			slow <- waited
+		}))
+		require.NoError(t, l.LockContext(context.Background(), 5*time.Millisecond))
+		time.Sleep(40 * time.Millisecond)
+		require.NoError(t, l.UnLock())
+		require.Len(t, slow, 0)
+	})
+}
@@ -0,0 +1,89 @@
+package fcntllock_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestTryLockFifo(t *testing.T) {
+	lockDir, cleanup := testhelper.Tempdir(t)
+	defer cleanup()
+	fifo := filepath.Join(lockDir, "fifo")
+	require.NoError(t, syscall.Mkfifo(fifo, 0600))
+	l := fcntllock.New(fifo)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- l.TryLock()
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+		require.NoError(t, l.UnLock())
+	case <-time.After(time.Second):
+		t.Fatal("TryLock on fifo hangs")
+	}
+}
+
+func TestWithRegularFileOnly(t *testing.T) {
+	t.Run("regular file is accepted", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithRegularFileOnly(true))
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("fifo is rejected", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		fifo := filepath.Join(lockDir, "fifo")
+		require.NoError(t, syscall.Mkfifo(fifo, 0600))
+		l := fcntllock.New(fifo, fcntllock.WithRegularFileOnly(true)).(*fcntllock.Lock)
+		require.ErrorIs(t, l.TryLock(), fcntllock.ErrNotRegularFile)
+		require.False(t, l.HeldByMe())
+	})
+
+	t.Run("device is rejected", func(t *testing.T) {
+		l := fcntllock.New("/dev/null", fcntllock.WithRegularFileOnly(true))
+		require.ErrorIs(t, l.TryLock(), fcntllock.ErrNotRegularFile)
+	})
+}
+
+func TestWithRefuseHardlinks(t *testing.T) {
+	t.Run("hardlinked lock file is accepted by default", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, os.Link(lockfile, lockfile+".data"))
+		defer func() { _ = os.Remove(lockfile + ".data") }()
+		l := fcntllock.New(lockfile)
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("hardlinked lock file is rejected", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, os.Link(lockfile, lockfile+".data"))
+		defer func() { _ = os.Remove(lockfile + ".data") }()
+		l := fcntllock.New(lockfile, fcntllock.WithRefuseHardlinks(true)).(*fcntllock.Lock)
+		require.ErrorIs(t, l.TryLock(), fcntllock.ErrHardlinkedLockFile)
+		require.False(t, l.HeldByMe())
+	})
+
+	t.Run("single link lock file is accepted", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile, fcntllock.WithRefuseHardlinks(true))
+		require.NoError(t, l.TryLock())
+		require.NoError(t, l.UnLock())
+	})
+}
@@ -0,0 +1,57 @@
+package fcntllock_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestStats(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	before := fcntllock.Stats()
+
+	forkCmd := lockInFork("TryLock", lockfile)
+	require.NoError(t, forkCmd.Start())
+	time.Sleep(50 * time.Millisecond)
+
+	l := fcntllock.New(lockfile).(*fcntllock.Lock)
+	require.NoError(t, l.LockContext(context.Background(), 10*time.Millisecond))
+	require.NoError(t, forkCmd.Wait())
+
+	s := fcntllock.Stats()
+	require.Equal(t, before.Acquisitions+1, s.Acquisitions)
+	require.Greater(t, s.Contentions, before.Contentions)
+	require.Equal(t, before.Held+1, s.Held)
+	require.Greater(t, int64(s.WaitTime-before.WaitTime), int64(20*time.Millisecond))
+
+	require.NoError(t, l.UnLock())
+	require.NoError(t, l.UnLock())
+	require.Equal(t, before.Held, fcntllock.Stats().Held, "held must be decremented once")
+}
+
+func TestWriteMetrics(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	l := fcntllock.New(lockfile)
+	require.NoError(t, l.TryLock())
+	defer func() { _ = l.UnLock() }()
+
+	var b bytes.Buffer
+	require.NoError(t, fcntllock.WriteMetrics(&b))
+	s := fcntllock.Stats()
+	text := b.String()
+	require.Contains(t, text, "# TYPE fcntllock_acquisitions_total counter\n")
+	require.Regexp(t, `(?m)^fcntllock_acquisitions_total [1-9][0-9]*$`, text)
+	require.Regexp(t, `(?m)^fcntllock_contentions_total [0-9]+$`, text)
+	require.Contains(t, text, "# TYPE fcntllock_held gauge\n")
+	require.Regexp(t, `(?m)^fcntllock_held [1-9][0-9]*$`, text)
+	require.Regexp(t, `(?m)^fcntllock_wait_seconds_total [0-9.e+-]+$`, text)
+	require.Greater(t, s.Held, int64(0))
+}
@@ -0,0 +1,33 @@
+package fcntllock_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithSuffix(t *testing.T) {
+	t.Run("Path returns the lock path when no suffix", func(t *testing.T) {
+		l := fcntllock.New("/tmp/base").(*fcntllock.Lock)
+		require.Equal(t, "/tmp/base", l.Path())
+	})
+
+	t.Run("suffix is applied to the created file", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		base := filepath.Join(lockDir, "base")
+		l := fcntllock.New(base, fcntllock.WithSuffix(".lock")).(*fcntllock.Lock)
+		require.Equal(t, base+".lock", l.Path())
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		_, err := os.Stat(base + ".lock")
+		require.NoError(t, err)
+		_, err = os.Stat(base)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
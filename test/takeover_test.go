@@ -0,0 +1,68 @@
+package fcntllock_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLockOrTakeover(t *testing.T) {
+	t.Run("free lock is acquired and its mtime updated", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		old := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(lockfile, old, old))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.LockOrTakeover(context.Background(), time.Minute))
+		require.True(t, l.HeldByMe())
+		info, err := os.Stat(lockfile)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), info.ModTime(), 5*time.Second)
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("fresh lock of a live holder is not taken over", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+
+		// the forked lock process holds the lock 102 milliseconds
+		forkCmd := startLockInFork(t, "TryLock", lockfile)
+		defer func() { _ = forkCmd.Wait() }()
+
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		t1 := time.Now()
+		err := l.LockOrTakeover(ctx, time.Minute)
+		require.ErrorIs(t, err, syscall.EAGAIN)
+		require.Less(t, int64(time.Since(t1)), int64(time.Second))
+		require.False(t, l.HeldByMe())
+	})
+
+	t.Run("stale lock is taken over when its holder dies", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+
+		forkCmd := startLockInFork(t, "TryLock", lockfile)
+		defer func() { _ = forkCmd.Wait() }()
+		old := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(lockfile, old, old))
+
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, l.LockOrTakeover(ctx, time.Minute))
+		require.True(t, l.HeldByMe())
+		info, err := os.Stat(lockfile)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), info.ModTime(), 5*time.Second)
+		require.NoError(t, l.UnLock())
+	})
+}
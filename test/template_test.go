@@ -0,0 +1,51 @@
+package fcntllock_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithCreateTemplate(t *testing.T) {
+	template := []byte("# managed by myapp, do not edit\n")
+
+	t.Run("template is written on creation", func(t *testing.T) {
+		lockDir, cleanup := testhelper.Tempdir(t)
+		defer cleanup()
+		lockfile := filepath.Join(lockDir, "lck")
+		l := fcntllock.New(lockfile, fcntllock.WithCreateTemplate(template))
+		require.NoError(t, l.TryLock())
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, template, b)
+
+		b, err = ioutil.ReadAll(l)
+		require.NoError(t, err)
+		require.Equal(t, template, b, "file offset is kept at the beginning")
+		require.NoError(t, l.UnLock())
+
+		require.NoError(t, ioutil.WriteFile(lockfile, nil, 0600))
+		require.NoError(t, l.TryLock())
+		b, err = ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Empty(t, b, "template is only written on first creation")
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("template is not written on reuse", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, nil, 0600))
+		l := fcntllock.New(lockfile, fcntllock.WithCreateTemplate(template))
+		require.NoError(t, l.TryLock())
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Empty(t, b)
+		require.NoError(t, l.UnLock())
+	})
+}
@@ -0,0 +1,68 @@
+package fcntllock_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWithTruncateOnLock(t *testing.T) {
+	stale := []byte("pid=1\nhost=stale\nleftover from a previous holder\n")
+
+	t.Run("lock file is empty after a truncating lock", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, stale, 0600))
+		l := fcntllock.New(lockfile, fcntllock.WithTruncateOnLock(true))
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Empty(t, b)
+	})
+
+	t.Run("lock file only contains the fresh metadata", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, stale, 0600))
+		l := fcntllock.New(lockfile, fcntllock.WithTruncateOnLock(true), fcntllock.WithMetadata())
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.NotContains(t, string(b), "stale")
+		m, err := fcntllock.ParseMetadata(b)
+		require.NoError(t, err)
+		require.Equal(t, os.Getpid(), m.PID)
+	})
+
+	t.Run("template is written again", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, stale, 0600))
+		template := []byte("# managed by myapp, do not edit\n")
+		l := fcntllock.New(lockfile, fcntllock.WithTruncateOnLock(true), fcntllock.WithCreateTemplate(template))
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, template, b)
+	})
+
+	t.Run("default keeps the content", func(t *testing.T) {
+		lockfile, cleanup := testhelper.TempFile(t)
+		defer cleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, stale, 0600))
+		l := fcntllock.New(lockfile)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, stale, b)
+	})
+}
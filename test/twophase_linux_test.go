@@ -0,0 +1,41 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestTwoPhaseLockSameProcess(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	child0 := fcntllock.NewTwoPhaseLock(lockfile, 0, 10*time.Millisecond)
+	child1 := fcntllock.NewTwoPhaseLock(lockfile, 1, 10*time.Millisecond)
+	require.NoError(t, child0.IntentLock(ctx))
+	require.NoError(t, child0.CommitLock(ctx))
+	require.NoError(t, child1.IntentLock(ctx))
+	require.NoError(t, child1.CommitLock(ctx))
+
+	same := fcntllock.NewTwoPhaseLock(lockfile, 1, 10*time.Millisecond)
+	require.NoError(t, same.IntentLock(ctx))
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer shortCancel()
+	require.ErrorIs(t, same.CommitLock(shortCtx), context.DeadlineExceeded, "child 1 is committed in the same process")
+	require.NoError(t, same.Release())
+
+	require.NoError(t, child0.Release())
+	require.False(t, rangeLockable(t, lockfile, 0, 1), "the child 1 intent must be kept")
+	require.False(t, rangeLockable(t, lockfile, 2, 1), "the child 1 commit must be kept")
+	require.True(t, rangeLockable(t, lockfile, 1, 1), "child 0 must be released")
+
+	require.NoError(t, child1.Release())
+	require.True(t, rangeLockable(t, lockfile, 0, 0))
+}
@@ -0,0 +1,72 @@
+package fcntllock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestTwoPhaseLock(t *testing.T) {
+	t.Run("intent holders coexist, commits conflict on the same child", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+
+		// start in fork an intent and child 0 commit holder during 102
+		// milliseconds
+		forkCmd := lockInFork("CommitLock", lockfile, "0")
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		same := fcntllock.NewTwoPhaseLock(lockfile, 0, 10*time.Millisecond)
+		require.NoError(t, same.IntentLock(context.Background()), "intents must coexist")
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, same.CommitLock(ctx), context.DeadlineExceeded, "child 0 is committed by the fork")
+
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, same.CommitLock(ctx), "child 0 commit after the fork release")
+		require.NoError(t, forkCmd.Wait())
+		require.NoError(t, same.Release())
+	})
+
+	t.Run("distinct children commits coexist", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		forkCmd := lockInFork("CommitLock", lockfile, "0")
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+
+		other := fcntllock.NewTwoPhaseLock(lockfile, 1, 10*time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		require.NoError(t, other.IntentLock(ctx))
+		require.NoError(t, other.CommitLock(ctx))
+		require.False(t, rangeLockable(t, lockfile, 2, 1), "child 1 must be locked")
+		require.NoError(t, other.Release())
+		require.NoError(t, forkCmd.Wait())
+		require.True(t, rangeLockable(t, lockfile, 0, 0))
+	})
+
+	t.Run("exclusive parent lock conflicts with intents", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		forkCmd := lockInFork("IntentLock", lockfile, "0")
+		require.NoError(t, forkCmd.Start())
+		time.Sleep(50 * time.Millisecond)
+		require.Error(t, fcntllock.New(lockfile).TryLock())
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("commit requires the intent", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.NewTwoPhaseLock(lockfile, 0, 10*time.Millisecond)
+		require.ErrorIs(t, l.CommitLock(context.Background()), fcntllock.ErrNotLocked)
+	})
+}
@@ -0,0 +1,74 @@
+package fcntllock_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWaitForUnlock(t *testing.T) {
+	t.Run("returns after release", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.WaitForUnlock(context.Background(), 10*time.Millisecond), "not held")
+
+		forkCmd := startLockInFork(t, "TryLock", lockfile)
+		require.NoError(t, l.WaitForUnlock(context.Background(), 10*time.Millisecond))
+		require.False(t, l.HeldByMe(), "the lock must not be acquired")
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		forkCmd := startLockInFork(t, "TryLock", lockfile)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, l.WaitForUnlock(ctx, 10*time.Millisecond), context.DeadlineExceeded)
+		require.NoError(t, forkCmd.Wait())
+	})
+}
+
+func TestWaitForUnlockBackoff(t *testing.T) {
+	lockfile, tfCleanup := testhelper.TempFile(t)
+	defer tfCleanup()
+	var buf bytes.Buffer
+	l := fcntllock.New(lockfile, fcntllock.WithLogger(log.New(&buf, "", 0))).(*fcntllock.Lock)
+
+	forkCmd := startLockInFork(t, "TryLock", lockfile)
+	begin := time.Now()
+	require.NoError(t, l.WaitForUnlockBackoff(context.Background(), 4*time.Millisecond, 32*time.Millisecond))
+	released := time.Now()
+	require.NoError(t, forkCmd.Wait())
+
+	// the fork holds the lock 102ms, and the poll delay is at most 32ms
+	require.Less(t, int64(released.Sub(begin)), int64(time.Second), "must return promptly")
+
+	var delays []time.Duration
+	for _, m := range regexp.MustCompile(`poll again in (\S+)\n`).FindAllStringSubmatch(buf.String(), -1) {
+		d, err := time.ParseDuration(m[1])
+		require.NoError(t, err)
+		delays = append(delays, d)
+	}
+	require.GreaterOrEqual(t, len(delays), 3)
+	// with jitter, each delay is between the half and the full backoff
+	backoff := 4 * time.Millisecond
+	for i, d := range delays {
+		require.GreaterOrEqual(t, int64(d), int64(backoff/2), "delay %d", i)
+		require.LessOrEqual(t, int64(d), int64(backoff), "delay %d", i)
+		if backoff *= 2; backoff > 32*time.Millisecond {
+			backoff = 32 * time.Millisecond
+		}
+	}
+	require.Greater(t, int64(delays[len(delays)-1]), int64(delays[0]), "the poll delay must grow")
+}
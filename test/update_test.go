@@ -0,0 +1,52 @@
+package fcntllock_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestUpdate(t *testing.T) {
+	t.Run("read modify write", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, []byte("counter=9 with a long tail"), 0600))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		err := l.Update(context.Background(), 10*time.Millisecond, func(old []byte) ([]byte, error) {
+			require.Equal(t, "counter=9 with a long tail", string(old))
+			return []byte("counter=10"), nil
+		})
+		require.NoError(t, err)
+		require.False(t, l.HeldByMe(), "the lock must be released")
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "counter=10", string(b), "the previous content must be truncated")
+		require.True(t, rangeLockable(t, lockfile, 0, 0))
+	})
+
+	t.Run("fn error", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		require.NoError(t, ioutil.WriteFile(lockfile, []byte("keep"), 0600))
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		fnErr := errors.New("fn failed")
+		err := l.Update(context.Background(), 10*time.Millisecond, func(old []byte) ([]byte, error) {
+			return []byte("overwritten"), fnErr
+		})
+		require.ErrorIs(t, err, fnErr)
+		require.False(t, l.HeldByMe(), "the lock must be released")
+		b, err := ioutil.ReadFile(lockfile)
+		require.NoError(t, err)
+		require.Equal(t, "keep", string(b), "the content must not be written")
+		require.True(t, rangeLockable(t, lockfile, 0, 0))
+	})
+}
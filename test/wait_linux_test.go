@@ -0,0 +1,89 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package fcntllock_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+// fcntlBlockedThreads returns the number of threads of the process blocked
+// in a fcntl system call
+func fcntlBlockedThreads(t *testing.T) int {
+	t.Helper()
+	files, err := filepath.Glob("/proc/self/task/*/syscall")
+	require.NoError(t, err)
+	var n int
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			// thread exited meanwhile
+			continue
+		}
+		fields := strings.Fields(string(b))
+		if len(fields) == 0 {
+			continue
+		}
+		if nr, err := strconv.Atoi(fields[0]); err == nil && nr == syscall.SYS_FCNTL {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLockWaitInterrupt(t *testing.T) {
+	for name, wait := range map[string]func(*fcntllock.Lock, context.Context) error{
+		"LockWait":  (*fcntllock.Lock).LockWait,
+		"RLockWait": (*fcntllock.Lock).RLockWait,
+	} {
+		wait := wait
+		t.Run(name+" cancellation releases the blocked thread", func(t *testing.T) {
+			lockfile, tfCleanup := testhelper.TempFile(t)
+			defer tfCleanup()
+			l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+			// the forked lock process holds the lock until SIGUSR1
+			forkCmd := startLockInFork(t, "TryLockUntilSignal", lockfile)
+			defer func() { _ = forkCmd.Wait() }()
+			defer func() { _ = forkCmd.Process.Signal(syscall.SIGUSR1) }()
+			require.Equal(t, 0, fcntlBlockedThreads(t))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			result := make(chan error, 1)
+			go func() { result <- wait(l, ctx) }()
+
+			blocked := false
+			for begin := time.Now(); time.Since(begin) < 5*time.Second; time.Sleep(time.Millisecond) {
+				if fcntlBlockedThreads(t) == 1 {
+					blocked = true
+					break
+				}
+			}
+			require.True(t, blocked, "expected a thread blocked in fcntl")
+
+			cancel()
+			select {
+			case err := <-result:
+				require.ErrorIs(t, err, context.Canceled)
+			case <-time.After(5 * time.Second):
+				t.Fatal("cancelled wait not returned")
+			}
+			require.Equal(t, 0, fcntlBlockedThreads(t), "expected the blocked thread released")
+			require.False(t, l.HeldByMe())
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package fcntllock_test
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestLockWait(t *testing.T) {
+	t.Run("acquired after another process releases the lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// the forked lock process holds the lock 102 milliseconds
+		forkCmd := startLockInFork(t, "TryLock", lockfile)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, l.LockWait(ctx))
+		require.True(t, l.HeldByMe())
+		require.NoError(t, forkCmd.Wait())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("cancelled wait returns promptly under scheduling churn", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 4*runtime.NumCPU(); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						runtime.Gosched()
+					}
+				}
+			}()
+		}
+		defer wg.Wait()
+		defer close(stop)
+
+		// the forked lock process holds the lock until SIGUSR1
+		forkCmd := startLockInFork(t, "TryLockUntilSignal", lockfile)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		t1 := time.Now()
+		require.ErrorIs(t, l.LockWait(ctx), context.DeadlineExceeded)
+		require.Less(t, int64(time.Since(t1)), int64(time.Second))
+		require.False(t, l.HeldByMe())
+
+		// the interrupted wait must not get the lock once released
+		require.NoError(t, forkCmd.Process.Signal(syscall.SIGUSR1))
+		require.NoError(t, forkCmd.Wait())
+		forkCmd = lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("self relock returns ErrWouldSelfDeadlock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.LockWait(context.Background()))
+		require.ErrorIs(t, l.LockWait(context.Background()), fcntllock.ErrWouldSelfDeadlock)
+		require.NoError(t, l.UnLock())
+	})
+}
+
+func TestRLockWait(t *testing.T) {
+	t.Run("acquired after the writer releases the lock", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// the forked lock process holds the lock 102 milliseconds
+		forkCmd := startLockInFork(t, "TryLock", lockfile)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, l.RLockWait(ctx))
+		typ, held := l.LockType()
+		require.True(t, held)
+		require.Equal(t, fcntllock.Shared, typ)
+		require.NoError(t, forkCmd.Wait())
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("not blocked by another reader", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		forkCmd := startLockInFork(t, "TryRLock", lockfile)
+		defer func() { _ = forkCmd.Wait() }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, l.RLockWait(ctx))
+		require.NoError(t, l.UnLock())
+	})
+
+	t.Run("cancelled mid-wait", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+		// the forked lock process holds the lock until SIGUSR1
+		forkCmd := startLockInFork(t, "TryLockUntilSignal", lockfile)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		t1 := time.Now()
+		require.ErrorIs(t, l.RLockWait(ctx), context.DeadlineExceeded)
+		require.Less(t, int64(time.Since(t1)), int64(time.Second))
+		require.False(t, l.HeldByMe())
+
+		// the interrupted wait must not get the lock once released
+		require.NoError(t, forkCmd.Process.Signal(syscall.SIGUSR1))
+		require.NoError(t, forkCmd.Wait())
+		forkCmd = lockInFork("TryLock", lockfile)
+		require.NoError(t, forkCmd.Start())
+		require.NoError(t, forkCmd.Wait())
+	})
+}
@@ -0,0 +1,75 @@
+package fcntllock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opensvc/fcntllock"
+)
+
+func TestWouldGrant(t *testing.T) {
+	t.Run("free", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		for _, typ := range []fcntllock.LockType{fcntllock.Shared, fcntllock.Exclusive} {
+			ok, pid, err := l.WouldGrant(typ)
+			require.NoError(t, err)
+			require.True(t, ok, typ.String())
+			require.Zero(t, pid)
+		}
+	})
+
+	t.Run("missing lock file", func(t *testing.T) {
+		l := fcntllock.New("/tmp/.no-such-fcntllock-file").(*fcntllock.Lock)
+		ok, _, err := l.WouldGrant(fcntllock.Exclusive)
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+
+	for _, tc := range []struct {
+		holder    string
+		shared    bool
+		exclusive bool
+	}{
+		{holder: "TryRLock", shared: true, exclusive: false},
+		{holder: "TryLock", shared: false, exclusive: false},
+	} {
+		tc := tc
+		t.Run("against a forked "+tc.holder+" holder", func(t *testing.T) {
+			lockfile, tfCleanup := testhelper.TempFile(t)
+			defer tfCleanup()
+			forkCmd := lockInFork(tc.holder, lockfile)
+			require.NoError(t, forkCmd.Start())
+			time.Sleep(50 * time.Millisecond)
+			l := fcntllock.New(lockfile).(*fcntllock.Lock)
+
+			ok, pid, err := l.WouldGrant(fcntllock.Shared)
+			require.NoError(t, err)
+			require.Equal(t, tc.shared, ok, "shared request")
+			if !ok {
+				require.Equal(t, forkCmd.Process.Pid, pid)
+			}
+
+			ok, pid, err = l.WouldGrant(fcntllock.Exclusive)
+			require.NoError(t, err)
+			require.Equal(t, tc.exclusive, ok, "exclusive request")
+			require.Equal(t, forkCmd.Process.Pid, pid)
+			require.NoError(t, forkCmd.Wait())
+		})
+	}
+
+	t.Run("own lock never conflicts", func(t *testing.T) {
+		lockfile, tfCleanup := testhelper.TempFile(t)
+		defer tfCleanup()
+		l := fcntllock.New(lockfile).(*fcntllock.Lock)
+		require.NoError(t, l.TryLock())
+		defer func() { _ = l.UnLock() }()
+		ok, _, err := l.WouldGrant(fcntllock.Exclusive)
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+}
@@ -0,0 +1,33 @@
+package fcntllock
+
+import (
+	"io"
+	"syscall"
+)
+
+// WithTruncateOnLock truncates the lock file to zero on each lock
+// acquisition, before the template and metadata writes, so no stale content
+// of a previous holder remains. The WithCreateTemplate template is written
+// again after the truncation.
+//
+// The default is to never truncate the lock file.
+func WithTruncateOnLock(v bool) Option {
+	return func(lck *Lock) {
+		lck.truncateOnLock = v
+	}
+}
+
+// truncate empties the lock file, and rewinds its offset
+func (lck *Lock) truncate() error {
+	if err := syscall.Ftruncate(int(lck.fd), 0); err != nil {
+		return err
+	}
+	lck.templatePending = len(lck.createTemplate) > 0
+	if !lck.templatePending {
+		if err := lck.grow(); err != nil {
+			return err
+		}
+	}
+	_, err := lck.Seek(0, io.SeekStart)
+	return err
+}
@@ -0,0 +1,106 @@
+package fcntllock
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// TwoPhaseLock is a hierarchical lock built on the byte ranges of a single
+// lock file: the parent byte 0 is locked shared by IntentLock, then the
+// child byte 1+child is locked exclusive by CommitLock.
+//
+// The intent holders coexist, and conflict only on the committed children.
+// An exclusive parent lock, like Lock, conflicts with all the intents.
+//
+// On linux, the ranges are locked with OFD locks, owned by the open lock file
+// of the TwoPhaseLock: the TwoPhaseLocks of a process conflict like those of
+// distinct processes, and the Release of one doesn't release the locks of the
+// others. Elsewhere, the classic locks are owned by the process, so a process
+// must use a single TwoPhaseLock per lock file.
+type TwoPhaseLock struct {
+	lck        *Lock
+	child      int64
+	retryDelay time.Duration
+
+	intent, committed bool
+}
+
+// NewTwoPhaseLock creates a TwoPhaseLock of the child region child (>= 0)
+// of the lock file path, retrying the contended phases every retryDelay.
+func NewTwoPhaseLock(path string, child int64, retryDelay time.Duration, opts ...Option) *TwoPhaseLock {
+	return &TwoPhaseLock{
+		lck:        New(path, append(opts, WithOFD(ofdSupported))...).(*Lock),
+		child:      child,
+		retryDelay: retryDelay,
+	}
+}
+
+// IntentLock acquires the shared intent lock on the parent, waiting until no
+// exclusive parent lock is held or ctx is Done.
+func (l *TwoPhaseLock) IntentLock(ctx context.Context) error {
+	if err := l.setRange(ctx, 0, unix.F_RDLCK); err != nil {
+		return err
+	}
+	l.intent = true
+	return nil
+}
+
+// CommitLock acquires the exclusive lock on the child, waiting until no
+// other process commits the same child or ctx is Done. The intent lock must
+// be held, or ErrNotLocked is returned.
+func (l *TwoPhaseLock) CommitLock(ctx context.Context) error {
+	if !l.intent {
+		return ErrNotLocked
+	}
+	if err := l.setRange(ctx, 1+l.child, unix.F_WRLCK); err != nil {
+		return err
+	}
+	l.committed = true
+	return nil
+}
+
+// Release releases the child then the intent locks, and closes the lock
+// file. On linux, the locks of the other TwoPhaseLocks of the process are
+// kept.
+func (l *TwoPhaseLock) Release() error {
+	if l.committed {
+		if err := l.setRangeLock(1+l.child, unix.F_UNLCK); err != nil {
+			return wrapPathErr(l.lck.path, "unlock", err)
+		}
+		l.committed = false
+	}
+	if l.intent {
+		if err := l.setRangeLock(0, unix.F_UNLCK); err != nil {
+			return wrapPathErr(l.lck.path, "unlock", err)
+		}
+		l.intent = false
+	}
+	return l.lck.closeFile()
+}
+
+// setRange locks the byte at offset with typ, retrying on contention
+func (l *TwoPhaseLock) setRange(ctx context.Context, offset int64, typ int16) error {
+	if err := l.lck.createLockDir(); err != nil {
+		return err
+	}
+	if l.lck.ReadWriteSeekCloser == nil {
+		if err := l.lck.open(); err != nil {
+			return wrapPathErr(l.lck.path, "open", err)
+		}
+	}
+	return l.lck.try(ctx, func() error {
+		return wrapPathErr(l.lck.path, "lock", l.setRangeLock(offset, typ))
+	}, l.retryDelay)
+}
+
+// setRangeLock sets the lock typ on the byte at offset, without waiting
+func (l *TwoPhaseLock) setRangeLock(offset int64, typ int16) error {
+	ft := rangeLock(offset, 1, typ)
+	cmd, err := l.lck.command(unix.F_SETLK, ft)
+	if err != nil {
+		return err
+	}
+	return fcntlFlock(l.lck.fd, cmd, ft)
+}
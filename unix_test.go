@@ -0,0 +1,53 @@
+package fcntllock
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// TestUnixParity verifies the x/sys/unix based locks are seen by, and see,
+// the syscall based locks.
+func TestUnixParity(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+
+	t.Run("syscall F_GETLK sees a unix lock", func(t *testing.T) {
+		forkCmd := lockInFork(t, lockfile)
+		f, err := os.Open(lockfile)
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+
+		sysFt := &syscall.Flock_t{Type: syscall.F_WRLCK}
+		require.NoError(t, syscall.FcntlFlock(f.Fd(), syscall.F_GETLK, sysFt))
+		unixFt := &unix.Flock_t{Type: unix.F_WRLCK}
+		require.NoError(t, unix.FcntlFlock(f.Fd(), unix.F_GETLK, unixFt))
+
+		require.Equal(t, int16(syscall.F_WRLCK), sysFt.Type)
+		require.Equal(t, int32(forkCmd.Process.Pid), sysFt.Pid)
+		require.Equal(t, sysFt.Type, unixFt.Type)
+		require.Equal(t, sysFt.Pid, unixFt.Pid)
+		require.Equal(t, sysFt.Start, unixFt.Start)
+		require.Equal(t, sysFt.Len, unixFt.Len)
+		require.NoError(t, forkCmd.Wait())
+	})
+
+	t.Run("unix lock attempt fails with the syscall errno", func(t *testing.T) {
+		forkCmd := lockInFork(t, lockfile)
+		lck := New(lockfile).(*Lock)
+		err := lck.TryLock()
+		require.Error(t, err)
+		var errno syscall.Errno
+		require.ErrorAs(t, err, &errno)
+		require.True(t, errno == syscall.EAGAIN || errno == syscall.EACCES, errno.Error())
+		require.True(t, lck.isContended(err))
+		require.NoError(t, forkCmd.Wait())
+
+		require.NoError(t, lck.TryLock())
+		require.NoError(t, lck.UnLock())
+	})
+}
@@ -0,0 +1,46 @@
+package fcntllock
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// WaitForUnlock waits until no other process holds a lock on the lock file,
+// polling every pollDelay, or ctx is Done. The lock is not acquired, so it
+// may be held again when WaitForUnlock returns.
+//
+// A missing lock file is not locked.
+func (lck *Lock) WaitForUnlock(ctx context.Context, pollDelay time.Duration) error {
+	return lck.waitForUnlock(ctx, func() time.Duration { return pollDelay })
+}
+
+// WaitForUnlockBackoff is WaitForUnlock with a poll delay growing
+// exponentially from base to max, with jitter, like the WithAdaptiveDelay
+// retry delay. It keeps the polling cheap for the long held locks.
+func (lck *Lock) WaitForUnlockBackoff(ctx context.Context, base, max time.Duration) error {
+	a := newAdaptiveDelay(base, max)
+	a.jitter = true
+	return lck.waitForUnlock(ctx, a.next)
+}
+
+func (lck *Lock) waitForUnlock(ctx context.Context, nextDelay func() time.Duration) error {
+	for {
+		status, err := Probe(lck.path)
+		switch {
+		case os.IsNotExist(err):
+			return nil
+		case err != nil:
+			return wrapPathErr(lck.path, "probe", err)
+		case !status.Held:
+			return nil
+		}
+		delay := nextDelay()
+		lck.logf("held by pid %d, poll again in %s", status.PID, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package fcntllock
+
+import (
+	"context"
+	"time"
+)
+
+// Update acquires the lock with LockContext, then replaces the whole lock
+// file content by the fn result, called with the current content, and
+// releases the lock.
+//
+// If fn returns an error, the content is not written, the lock is released
+// and the fn error is returned. ErrNoFd is returned, and the lock released,
+// when the lock is held without lock file, like with a lock backend.
+func (lck *Lock) Update(ctx context.Context, retryDelay time.Duration, fn func(old []byte) (new []byte, err error)) (err error) {
+	if err = lck.LockContext(ctx, retryDelay); err != nil {
+		return
+	}
+	defer func() {
+		if uerr := lck.UnLock(); err == nil {
+			err = uerr
+		}
+	}()
+	if err = lck.requireFile(); err != nil {
+		return
+	}
+	old, err := lck.readContent()
+	if err != nil {
+		return wrapPathErr(lck.path, "read", err)
+	}
+	b, err := fn(old)
+	if err != nil {
+		return err
+	}
+	return wrapPathErr(lck.path, "write", lck.writeContent(b))
+}
@@ -0,0 +1,150 @@
+package fcntllock
+
+import (
+	"context"
+	"runtime"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// LockWait acquires an exclusive write file lock, waiting for the lock
+// release (blocking) until ctx is done.
+//
+// The blocking wait runs on a goroutine pinned to its OS thread, so the wait
+// never migrates and the thread is dedicated to the fcntl call. When ctx is
+// done, the pending fcntl call is interrupted by a signal sent to this
+// thread, the real-time signal 63 on linux, and LockWait returns ctx.Err()
+// once the call has returned. A lock granted concurrently with the
+// cancellation is kept, and LockWait returns nil. The application must not
+// ignore, reset or be notified of the interrupt signal.
+//
+// Where the blocked thread can't be interrupted, like on the other
+// platforms, LockWait polls the lock with non blocking attempts instead.
+//
+// LockWait returns ErrWouldSelfDeadlock when the lock is already held by lck,
+// unless WithReentrant is set.
+func (lck *Lock) LockWait(ctx context.Context) error {
+	return lck.lockWait(ctx, Exclusive)
+}
+
+// RLockWait acquires a shared read file lock, waiting for the release of the
+// exclusive lock (blocking) until ctx is done. The wait and its cancellation
+// behave like LockWait.
+func (lck *Lock) RLockWait(ctx context.Context) error {
+	return lck.lockWait(ctx, Shared)
+}
+
+const (
+	// waitPollDelay is the retry delay of the LockWait polling, where the
+	// blocked thread can't be interrupted
+	waitPollDelay = 10 * time.Millisecond
+
+	// interruptRetryDelay is the delay between the interrupt signals sent
+	// to the thread of a cancelled LockWait, until its fcntl call returns
+	interruptRetryDelay = 10 * time.Millisecond
+)
+
+func (lck *Lock) lockWait(ctx context.Context, typ LockType) error {
+	return wrapPathErr(lck.path, "lock", lck.waitLock(ctx, typ))
+}
+
+func (lck *Lock) waitLock(ctx context.Context, typ LockType) error {
+	if lck.held && !lck.reentrant {
+		return ErrWouldSelfDeadlock
+	}
+	if err := lck.checkIdentity(); err != nil {
+		return err
+	}
+	if err := lck.setupDir(); err != nil {
+		return err
+	}
+	ctx, cancel := lck.withBase(ctx)
+	defer cancel()
+	release, err := acquireSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := prepareInterrupt(); err != nil {
+		lck.logf("blocked thread interruption not supported, poll the lock")
+		return lck.try(ctx, func() error { return lck.setLock(typ, false) }, waitPollDelay)
+	}
+	if lck.ReadWriteSeekCloser == nil {
+		if err := lck.open(); err != nil {
+			return err
+		}
+	}
+	ft := wholeFileLock(int16(typ))
+	cmd, err := lck.command(unix.F_SETLKW, ft)
+	if err != nil {
+		return err
+	}
+	if err := lck.guarded(ft.Type, func() error { return blockingWait(ctx, lck.fd, cmd, ft) }); err != nil {
+		return err
+	}
+	lck.heldType = typ
+	lck.heldStart, lck.heldLen = 0, 0
+	lck.mode = lck.fcntlMode()
+	return lck.onAcquired()
+}
+
+// blockingWait runs the blocking lock command cmd with ft on fd, waiting for
+// the conflicting locks release, on a goroutine pinned to its OS thread.
+//
+// When ctx is done, the thread is interrupted by a signal, sent again until
+// the command returns, so the goroutine never outlives the call.
+func blockingWait(ctx context.Context, fd uintptr, cmd int, ft *unix.Flock_t) error {
+	tid := make(chan int, 1)
+	result := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		tid <- gettid()
+		result <- blockingLock(ctx, fd, cmd, ft)
+		if ctx.Err() == nil {
+			// no interrupt signal is sent to the thread
+			runtime.UnlockOSThread()
+		}
+		// else the thread, maybe targeted by a pending interrupt signal,
+		// is terminated with the goroutine
+	}()
+	thread := <-tid
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+	}
+	ticker := time.NewTicker(interruptRetryDelay)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-result:
+			return err
+		default:
+		}
+		// a signal received before the thread enters the fcntl call
+		// doesn't interrupt it, so the signal is sent on each tick
+		_ = interruptThread(thread)
+		select {
+		case err := <-result:
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// blockingLock runs the blocking lock command cmd with ft on fd, retrying
+// the calls interrupted by a signal until ctx is done. The caller goroutine
+// must be pinned to its OS thread.
+func blockingLock(ctx context.Context, fd uintptr, cmd int, ft *unix.Flock_t) error {
+	for {
+		err := fcntlFlock(fd, cmd, ft)
+		if err != syscall.EINTR {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package fcntllock
+
+import (
+	"time"
+)
+
+// WithRecordWait records in the lock file Metadata the wait of the latest
+// LockContext acquisition, so the lock file carries the last contention
+// seen by its holder. It implies WithMetadata.
+//
+// Only the latest wait is kept, and the acquisitions without wait, like
+// TryLock, record none.
+func WithRecordWait() Option {
+	return func(lck *Lock) {
+		lck.metadata = true
+		lck.recordWait = true
+	}
+}
+
+// recordWaited writes wait, the wait of the acquired lock, in the held lock
+// metadata, if WithRecordWait is set. The lock is released if the write
+// fails.
+func (lck *Lock) recordWaited(wait time.Duration) error {
+	if !lck.recordWait || !lck.held || lck.meta == nil {
+		return nil
+	}
+	if err := lck.writeMetadata(func(m *Metadata) { m.Waited = wait }); err != nil {
+		_ = lck.UnLock()
+		return wrapPathErr(lck.path, "write", err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package fcntllock
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// fcntlGuard serializes the lock calls of a Lock with the release of the
+// locks set by its orphaned calls
+type fcntlGuard struct {
+	mu sync.Mutex
+
+	// set is true when the last lock call returned by the Lock set a lock,
+	// false when it released it
+	set bool
+
+	// orphans is the number of orphaned calls not returned yet
+	orphans int
+
+	// closers are the lock files closed by the Lock while still used by
+	// orphaned calls. Their close is deferred until no lock call needs them.
+	closers []io.Closer
+}
+
+// WithFcntlTimeout runs the non blocking fcntl lock calls under a watchdog:
+// a call not returned within d fails with ErrFcntlTimeout, for example on a
+// buggy NFS mount where even F_SETLK can hang.
+//
+// The timed out call is not cancelled: it is orphaned, keeping its goroutine,
+// its OS thread and the lock file open until it returns, if ever. A lock
+// set by an orphaned call is released when it returns, unless lck is held
+// meanwhile. Each timed out attempt orphans a new call, so a LockContext
+// retry loop on a stuck mount accumulates threads.
+func WithFcntlTimeout(d time.Duration) Option {
+	return func(lck *Lock) {
+		lck.fcntlTimeout = d
+		if d > 0 {
+			lck.guard = &fcntlGuard{}
+		} else {
+			lck.guard = nil
+		}
+	}
+}
+
+// fcntl calls fcntlFlock on the lock file descriptor, under the
+// WithFcntlTimeout watchdog for the non blocking lock calls
+//
+// cmd is a classic lock command, run as its OFD variant if WithOFD is set.
+func (lck *Lock) fcntl(cmd int, ft *unix.Flock_t) error {
+	realCmd, err := lck.command(cmd, ft)
+	if err != nil {
+		return err
+	}
+	g := lck.guard
+	if g == nil || cmd == unix.F_GETLK {
+		return fcntlFlock(lck.fd, realCmd, ft)
+	}
+	if cmd != unix.F_SETLK {
+		return lck.guarded(ft.Type, func() error { return fcntlFlock(lck.fd, realCmd, ft) })
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	flock := fcntlFlock
+	fd, typ := lck.fd, ft.Type
+	result := make(chan error, 1)
+	go func() {
+		result <- flock(fd, realCmd, ft)
+	}()
+	timer := time.NewTimer(lck.fcntlTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		if err == nil {
+			g.record(typ)
+		}
+		return err
+	case <-timer.C:
+		lck.logf("fcntl call timeout after %s", lck.fcntlTimeout)
+		g.orphans++
+		go g.adopt(result, flock, fd, realCmd, typ)
+		return ErrFcntlTimeout
+	}
+}
+
+// guarded runs fn, a lock call of type typ not run under the watchdog, with
+// the guard mutex held, so an orphaned call returning meanwhile doesn't
+// release the lock it sets
+func (lck *Lock) guarded(typ int16, fn func() error) error {
+	g := lck.guard
+	if g == nil {
+		return fn()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	err := fn()
+	if err == nil {
+		g.record(typ)
+	}
+	return err
+}
+
+// record updates the guard state after a successful lock call of type typ,
+// and closes the deferred closers no longer needed
+func (g *fcntlGuard) record(typ int16) {
+	g.set = typ != unix.F_UNLCK
+	g.closeDeferred()
+}
+
+// adopt waits for the orphaned lock call result, and releases with flock the
+// lock it set on fd unless the Lock set a lock meanwhile. The lock file of fd
+// is kept open by closeFile until then.
+func (g *fcntlGuard) adopt(result <-chan error, flock func(uintptr, int, *unix.Flock_t) error, fd uintptr, cmd int, typ int16) {
+	err := <-result
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.orphans--
+	if err == nil && typ != unix.F_UNLCK && !g.set {
+		_ = flock(fd, cmd, wholeFileLock(unix.F_UNLCK))
+	}
+	g.closeDeferred()
+}
+
+// closeDeferred closes the lock files closed by the Lock during orphaned
+// calls, once no orphaned call uses them and closing them can't drop a lock
+// set by the Lock. It must be called with the guard mutex held.
+func (g *fcntlGuard) closeDeferred() {
+	if g.orphans > 0 || g.set {
+		return
+	}
+	for _, c := range g.closers {
+		_ = c.Close()
+	}
+	g.closers = nil
+}
+
+// deferClose defers the close of the lock file c if orphaned calls may still
+// use it. It returns false if c can be closed now.
+func (lck *Lock) deferClose(c io.Closer) bool {
+	g := lck.guard
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	// closing the file drops the classic locks of the process on it
+	g.set = false
+	if g.orphans == 0 {
+		return false
+	}
+	g.closers = append(g.closers, c)
+	return true
+}
@@ -0,0 +1,86 @@
+package fcntllock
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opensvc/testhelper"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// hangingFcntl makes the F_SETLK lock calls sleep d before calling
+// unix.FcntlFlock, until the returned restore func is called
+func hangingFcntl(d time.Duration) (restore func()) {
+	return injectFcntl(func(fd uintptr, cmd int, lk *unix.Flock_t) error {
+		if cmd == unix.F_SETLK && lk.Type != unix.F_UNLCK {
+			time.Sleep(d)
+		}
+		return unix.FcntlFlock(fd, cmd, lk)
+	})
+}
+
+func TestWithFcntlTimeout(t *testing.T) {
+	lockfile, cleanup := testhelper.TempFile(t)
+	defer cleanup()
+
+	t.Run("watchdog fires on a hanging fcntl", func(t *testing.T) {
+		defer hangingFcntl(100 * time.Millisecond)()
+		lck := New(lockfile, WithFcntlTimeout(20*time.Millisecond)).(*Lock)
+		begin := time.Now()
+		err := lck.TryLock()
+		require.ErrorIs(t, err, ErrFcntlTimeout)
+		require.Less(t, int64(time.Since(begin)), int64(80*time.Millisecond))
+		require.False(t, lck.HeldByMe())
+
+		// the lock set by the orphaned call is released when it returns
+		time.Sleep(120 * time.Millisecond)
+		forkCmd := lockInFork(t, lockfile)
+		require.NoError(t, forkCmd.Wait(), "the orphaned call lock must be released")
+	})
+
+	t.Run("lock set meanwhile is not released by the orphaned call", func(t *testing.T) {
+		var calls int32
+		defer injectFcntl(func(fd uintptr, cmd int, lk *unix.Flock_t) error {
+			if cmd == unix.F_SETLK && lk.Type != unix.F_UNLCK && atomic.AddInt32(&calls, 1) == 1 {
+				time.Sleep(100 * time.Millisecond)
+			}
+			return unix.FcntlFlock(fd, cmd, lk)
+		})()
+		lck := New(lockfile, WithFcntlTimeout(20*time.Millisecond)).(*Lock)
+		require.ErrorIs(t, lck.TryLock(), ErrFcntlTimeout)
+		require.NoError(t, lck.TryLock())
+
+		time.Sleep(120 * time.Millisecond)
+		require.Error(t, tryLockInFork(lockfile), "the lock of lck must be kept")
+		require.NoError(t, lck.UnLock())
+		require.NoError(t, lck.Close())
+	})
+
+	t.Run("lock file closed meanwhile stays open for the orphaned call", func(t *testing.T) {
+		fdErr := make(chan error, 1)
+		defer injectFcntl(func(fd uintptr, cmd int, lk *unix.Flock_t) error {
+			if cmd == unix.F_SETLK && lk.Type != unix.F_UNLCK {
+				time.Sleep(100 * time.Millisecond)
+				_, err := unix.FcntlInt(fd, unix.F_GETFD, 0)
+				fdErr <- err
+			}
+			return unix.FcntlFlock(fd, cmd, lk)
+		})()
+		lck := New(lockfile, WithFcntlTimeout(20*time.Millisecond)).(*Lock)
+		require.ErrorIs(t, lck.TryLock(), ErrFcntlTimeout)
+		require.NoError(t, lck.Close())
+		require.NoError(t, <-fdErr, "the orphaned call fd must stay open")
+
+		time.Sleep(20 * time.Millisecond)
+		forkCmd := lockInFork(t, lockfile)
+		require.NoError(t, forkCmd.Wait(), "the orphaned call lock must be released")
+	})
+
+	t.Run("no timeout on a fast fcntl", func(t *testing.T) {
+		lck := New(lockfile, WithFcntlTimeout(time.Second)).(*Lock)
+		require.NoError(t, lck.TryLock())
+		require.NoError(t, lck.UnLock())
+	})
+}
@@ -0,0 +1,33 @@
+package fcntllock
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// WouldGrant reports if a lock request of type typ would be granted right
+// now, using F_GETLK without acquiring the lock. When not grantable,
+// holderPID is a process holding a conflicting lock: a Shared request
+// conflicts only with an Exclusive holder, an Exclusive request with any
+// holder.
+//
+// The locks held by the calling process never conflict.
+func (lck *Lock) WouldGrant(typ LockType) (grantable bool, holderPID int, err error) {
+	ft := wholeFileLock(int16(typ))
+	getlk := func(fd uintptr) error {
+		return wrapPathErr(lck.path, "probe", fcntlFlock(fd, unix.F_GETLK, ft))
+	}
+	if lck.ReadWriteSeekCloser != nil {
+		err = getlk(lck.fd)
+	} else if err = withFileFd(lck.path, getlk); os.IsNotExist(err) {
+		return true, 0, nil
+	}
+	if err != nil {
+		return false, 0, wrapPathErr(lck.path, "open", err)
+	}
+	if ft.Type == unix.F_UNLCK {
+		return true, 0, nil
+	}
+	return false, int(ft.Pid), nil
+}